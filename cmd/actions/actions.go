@@ -4,6 +4,9 @@ Copyright © 2023 Koalalab Inc <dev@koalalab.com>
 package actions
 
 import (
+	"time"
+
+	"github.com/koalalab-inc/pinny/pkg/actions"
 	"github.com/spf13/cobra"
 )
 
@@ -19,12 +22,46 @@ Usage:
 
 	GITHUB_TOKEN=<your personal access token> {{.UseLine}}
 
+	GH_TOKEN, the token of the gh CLI, ~/.netrc and Github App installations
+	are picked up as well. Run pinny auth status to see which one is used.
+
+	Requests that hit a rate limit are retried once the limit resets, and
+	the API quota left is printed to stderr when a command finishes.
+
 	Read more about Github Personal Access Tokens here:
 	https://docs.github.com/en/authentication/keeping-your-account-and-data-secure/managing-your-personal-access-tokens
 
+	Actions hosted on Github Enterprise Server are resolved by pointing
+	--github-api-url (or the GITHUB_API_URL environment variable) at the
+	server, and --github-host routes the actions of a single owner to
+	another host. Set GH_ENTERPRISE_TOKEN to authenticate with the server,
+	and GITHUB_COM_TOKEN for owners routed to github.com. The token of the
+	--github-api-url host, like the GITHUB_TOKEN of a runner, is never sent
+	to another host.
+
+	pinny actions pin --github-api-url https://github.example.com/api/v3 \
+		--github-host actions=https://api.github.com
+
+	Workflows in .github/workflows, composite actions below .github/actions,
+	workflow templates in workflow-templates and a root action.yml are all
+	worked on. Use --path, once per file or directory, to work on other
+	locations instead.
+
+	pinny actions pin --path ci/workflows --path tools/action.yml
+
+	Actions pinned to a commit SHA are checked for impostor commits by
+	comparing the commit against the default branch of the repo first, then
+	against its release tags, newest first, and then every other ref. Use
+	--impostor-budget to change how many comparisons are made per commit, 0
+	for no limit. A commit no ref was found to contain within the budget is
+	reported as inconclusive rather than as an impostor.
+
 Options:
 	{{.LocalFlags.FlagUsages | trimRightSpace}}
-{{if gt (len .Commands) 0}}
+{{if .HasAvailableInheritedFlags}}
+Global Options:
+	{{.InheritedFlags.FlagUsages | trimRightSpace}}
+{{end}}{{if gt (len .Commands) 0}}
 Available Commands:
 {{range .Commands}}{{if .IsAvailableCommand}}
 	{{rpad .Name .NamePadding}} {{.Short}}{{end}}{{end}}
@@ -38,12 +75,37 @@ Description:
 var ActionsCmd = &cobra.Command{
 	Use:   "actions",
 	Short: "\nHash-pining for your third party Github Actions",
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if cmd.Annotations[noQuotaAnnotation] == "" {
+			printQuota(cmd)
+		}
+	},
+}
+
+// noQuotaAnnotation marks the commands that print no quota report after they
+// ran, e.g. because their output is read by scripts.
+const noQuotaAnnotation = "pinny.no-quota"
+
+// printQuota prints the Github API quota left on every host the command sent
+// requests to, on stderr like warnings.
+func printQuota(cmd *cobra.Command) {
+	for _, quota := range actions.QuotaUsage() {
+		cmd.PrintErrf("%s: %d requests made, %d/%d %s API requests left, resets at %s\n", quota.Host, quota.Requests, quota.Remaining, quota.Limit, quota.Resource, quota.Reset.Format(time.TimeOnly))
+	}
 }
 
 func init() {
+	ActionsCmd.PersistentFlags().IntVar(&actions.ImpostorBudget, "impostor-budget", actions.ImpostorBudget, "Comparisons made per pinned commit to find a ref containing it, 0 for no limit")
+	ActionsCmd.PersistentFlags().StringSliceVar(&actions.Paths, "path", nil, "Workflow or action files and directories to work on instead of the default locations")
+
 	commands := []*cobra.Command{
 		pinCmd,
 		digestCmd,
+		auditCmd,
+		updateCmd,
+		lockCmd,
+		transformCmd,
+		unpinCmd,
 	}
 	for _, cmd := range commands {
 		cmd.SetHelpTemplate(actionsHelpTemplate)
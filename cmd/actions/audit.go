@@ -0,0 +1,193 @@
+/*
+Copyright © 2023 Koalalab Inc <dev@koalalab.com>
+*/
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/actions"
+	"github.com/koalalab-inc/pinny/pkg/advisory"
+	"github.com/koalalab-inc/pinny/pkg/findings"
+	"github.com/spf13/cobra"
+)
+
+var transitive bool
+var outputFormat string
+var advisoryDB []string
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit the Github Actions used in your workflows",
+	Long: `
+	Audit the Github Actions used in your workflows. Every uses key in the
+	workflow files in your .github/workflows directory is resolved and
+	reported along with its pinning status, and so is every job container
+	and service image. No files are modified.
+
+	Commits are checked for impostor commits, see docs/impostorcommits.md,
+	and every action is matched against advisories and checked for
+	repojacking, retired runtimes and a signed tag or commit. Use
+	--transitive to audit the actions used by composite actions and reusable
+	workflows as well, and --advisory-db to add advisories exported to disk.
+
+	References are marked in the report:
+	[UNPINNED]     the reference is a tag, short SHA or docker image tag
+	[BRANCH]       the reference is a branch
+	[FORK-NETWORK] the commit is only reachable from a fork of the repo
+	[MISSING]      the commit does not exist
+	[INCONCLUSIVE] no ref containing the commit was found within
+	               --impostor-budget comparisons
+	[ADVISORY]     the commit or its release is affected by an advisory
+	[UNSIGNED]     the tag or commit is not signed
+	[UNVERIFIED]   the signature could not be verified by Github
+	[REPOJACKABLE] the repo has moved or its owner does not exist
+	[DEPRECATED]   the repo is archived or the action runs on a retired
+	               runtime
+	[TRUSTED]      the reference is a tag the .pinny.yaml policy trusts
+	[POLICY]       the reference breaks a rule of the .pinny.yaml policy
+
+	The command exits with status 2 for an impostor commit, 5 for a
+	repojackable action, 4 for an advisory, 3 for a policy violation and 6
+	for a reference that could not be checked. Use --format json for a
+	machine readable report.
+
+	e.g.:
+	|> pinny actions audit --transitive
+	| .github/workflows/build.yaml
+	|   actions/checkout@v3 (line 14) [UNPINNED] [DEPRECATED]
+	|     runs on node16, which Github Actions no longer supports, replace with actions/checkout@v4.1.1
+	|   some/composite@0b1c3e7d5b1f4ec0c97e5a8e2b0cfe1d36d7e2b1 (line 17)
+	|     actions/cache@v3 (line 9) [UNPINNED]
+	|   tj-actions/changed-files@0e58ed8671d6b60d0890c21b07f8835ace038e67 (line 21) [ADVISORY]
+	|     GHSA-mrrh-fwg8-r2c3: tj-actions/changed-files was compromised, ..., upgrade to v46.0.1
+	|
+	| 2 unpinned or mutable references found
+	| 0 impostor commits found
+	| 0 inconclusive impostor checks
+	| 0 repojackable actions found
+	| 1 advisories found
+	| 1 deprecated actions found
+	| 0 policy violations found
+	| 0 references could not be checked
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if outputFormat != "text" && outputFormat != "json" {
+			cobra.CheckErr(fmt.Errorf("invalid format %q, expected text or json", outputFormat))
+		}
+
+		advisories, err := advisory.Load(advisoryDB)
+		cobra.CheckErr(err)
+
+		report, err := actions.AuditWorkflows(transitive, advisories)
+		cobra.CheckErr(err)
+
+		switch outputFormat {
+		case "json":
+			reportJSON, err := json.MarshalIndent(report, "", "    ")
+			cobra.CheckErr(err)
+			cmd.OutOrStdout().Write(append(reportJSON, '\n'))
+		default:
+			for _, audit := range report.Workflows {
+				cmd.Println(audit.File)
+				for _, dep := range audit.Dependencies {
+					printDependency(cmd, dep, 1)
+				}
+			}
+			cmd.Printf("\n%d unpinned or mutable references found\n", report.Mutable)
+			cmd.Printf("%d impostor commits found\n", report.Impostors)
+			cmd.Printf("%d inconclusive impostor checks\n", report.Inconclusive)
+			cmd.Printf("%d repojackable actions found\n", report.Repojackable)
+			cmd.Printf("%d advisories found\n", report.Advisories)
+			cmd.Printf("%d deprecated actions found\n", report.Deprecated)
+			cmd.Printf("%d policy violations found\n", report.Violations)
+			cmd.Printf("%d references could not be checked\n", report.Errors)
+		}
+
+		if report.Impostors > 0 {
+			printQuota(cmd)
+			os.Exit(findings.ExitImpostor)
+		}
+		if report.Repojackable > 0 {
+			printQuota(cmd)
+			os.Exit(findings.ExitRepojack)
+		}
+		if report.Advisories > 0 {
+			printQuota(cmd)
+			os.Exit(findings.ExitAdvisory)
+		}
+		if report.Violations > 0 {
+			printQuota(cmd)
+			os.Exit(findings.ExitPolicy)
+		}
+		if report.Errors > 0 || report.Inconclusive > 0 {
+			printQuota(cmd)
+			os.Exit(findings.ExitUnverified)
+		}
+	},
+}
+
+func init() {
+	auditCmd.Flags().BoolVarP(&transitive, "transitive", "t", false, "Audit the dependencies of composite actions and reusable workflows")
+	auditCmd.Flags().StringVarP(&outputFormat, "format", "o", "text", "Output format: text or json")
+	auditCmd.Flags().StringSliceVar(&advisoryDB, "advisory-db", []string{}, "Advisory files or directories to match actions against, can be repeated")
+}
+
+func printDependency(cmd *cobra.Command, dep *actions.ActionDependency, depth int) {
+	line := fmt.Sprintf("%s%s", strings.Repeat("  ", depth), dep.Uses)
+	if dep.Line > 0 {
+		line = fmt.Sprintf("%s (line %d)", line, dep.Line)
+	}
+	if dep.Mutable() && dep.Trusted {
+		line = fmt.Sprintf("%s [TRUSTED]", line)
+	} else if dep.Mutable() {
+		line = fmt.Sprintf("%s [%s]", line, strings.ToUpper(dep.Status))
+	}
+	if dep.Impostor() || dep.Reachability == actions.ReachableInconclusive {
+		line = fmt.Sprintf("%s [%s]", line, strings.ToUpper(dep.Reachability))
+	}
+	if dep.Repojacking != "" {
+		line = fmt.Sprintf("%s [REPOJACKABLE]", line)
+	}
+	if dep.Signature.Verified() {
+		line = fmt.Sprintf("%s signed by %s", line, dep.Signature.Signer)
+	} else if dep.Signature != nil {
+		line = fmt.Sprintf("%s [%s]", line, strings.ToUpper(dep.Signature.Status))
+	}
+	if len(dep.Advisories) > 0 {
+		line = fmt.Sprintf("%s [ADVISORY]", line)
+	}
+	if len(dep.Deprecations) > 0 {
+		line = fmt.Sprintf("%s [DEPRECATED]", line)
+	}
+	violations := dep.Violations()
+	if len(violations) > 0 {
+		line = fmt.Sprintf("%s [POLICY]", line)
+	}
+	if dep.Error != "" {
+		line = fmt.Sprintf("%s ERROR: %s", line, dep.Error)
+	}
+	cmd.Println(line)
+	if dep.Repojacking != "" {
+		cmd.Printf("%s  %s\n", strings.Repeat("  ", depth), dep.Repojacking)
+	}
+	if dep.Signature != nil && dep.Signature.Status == actions.SignatureUnverified {
+		cmd.Printf("%s  %s\n", strings.Repeat("  ", depth), dep.Signature)
+	}
+	for _, match := range dep.Advisories {
+		cmd.Printf("%s  %s\n", strings.Repeat("  ", depth), match)
+	}
+	for _, deprecation := range dep.Deprecations {
+		cmd.Printf("%s  %s\n", strings.Repeat("  ", depth), deprecation)
+	}
+	for _, violation := range violations {
+		cmd.Printf("%s  %s (%s)\n", strings.Repeat("  ", depth), violation.Message, violation.Rule)
+	}
+	for _, child := range dep.Dependencies {
+		printDependency(cmd, child, depth+1)
+	}
+}
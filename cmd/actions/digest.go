@@ -33,6 +33,9 @@ var digestCmd = &cobra.Command{
 	
 `,
 	Args: cobra.ExactArgs(1),
+	// the digest is printed without a newline for use in scripts, keep the
+	// quota report from being appended to it
+	Annotations: map[string]string{noQuotaAnnotation: "true"},
 
 	Run: func(cmd *cobra.Command, args []string) {
 		actionString := args[0]
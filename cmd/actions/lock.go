@@ -0,0 +1,50 @@
+/*
+Copyright © 2023 Koalalab Inc <dev@koalalab.com>
+*/
+package actions
+
+import (
+	"github.com/koalalab-inc/pinny/pkg/actions"
+	"github.com/spf13/cobra"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Generate a lock file for the Github Actions used in your workflows",
+	Long: `
+	Generate a lock file for the Github Actions and docker images used in
+	the workflow files in your .github/workflows directory.
+	A pinny-actions-lock.json file will be generated in the current directory.
+	Entries already present in the lock file are kept.
+
+	Commit the lock file and use pinny actions transform in CI to pin your
+	workflows without calling the Github API.
+
+	Example:
+		pinny actions lock
+
+	A sample pinny-actions-lock.json file looks like this:
+	| {
+	|     "actions": {
+	|         "actions/checkout@v3": {
+	|             "digest": "f43a0e5ff2bd294095638e18286ca9a3d1956744",
+	|             "other_refs": [
+	|                 "v3.6.0"
+	|             ]
+	|         }
+	|     },
+	|     "images": {
+	|         "docker://alpine:3.18": "sha256:eece025e432126ce23f223450a0326fbebde39cdf496a85d8c016293fc851978"
+	|     },
+	|     "generated_at": "Tue, 28 Nov 2023 13:25:30 IST",
+	|     "generated_by": "Pinny"
+	| }
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := actions.GeneratePinnyLockFile()
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {}
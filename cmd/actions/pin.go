@@ -6,31 +6,50 @@ package actions
 import (
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/koalalab-inc/pinny/pkg/actions"
+	"github.com/koalalab-inc/pinny/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
 var dryRun bool
 
-var workflowDir = ".github/workflows"
+// showDiff prints a unified diff of every file that changes instead of
+// updating it
+var showDiff bool
 
 var pinCmd = &cobra.Command{
 	Use:   "pin",
 	Short: "Pin all third party Github Actions used in your workflows",
 	Long: `
 	Pin all third party Github Actions used in your workflows. This will
-	update the workflow files in your .github/workflows directory to use
-	the digest of the action instead of the ref.
+	update the workflow files in your .github/workflows directory, the
+	composite actions below .github/actions, the workflow templates in
+	workflow-templates and a root action.yml to use the digest of the action
+	instead of the ref. Use --path to pin other locations.
 
 	This command will look for all the occurences of uses key in your workflow
 	files and update them to use the digest of the action instead of the ref.
 
-	You can expet to see output with substitutions like this:
+	You can expet to see output with substitutions like this, once the files
+	are updated:
 	actions/checkout@v3.1.0 -> actions/checkout@93ea575cb5d8a053eaa0ac8fa3b40d7e05a33cc8
 
-	Workflow files are updated in place. 
+	Job container and service images, docker:// actions and the base images
+	of the Dockerfiles of docker container actions are pinned to their
+	digest as well. The refs named in the comments of actions that are
+	already pinned are checked to still point to the pinned commit.
+
+	The trailing comment names the version and the ref that was pinned,
+	change it with --comment-template:
+	| --comment-template '{version} | {ref}' # v3.5.3 | v3 (default)
+	| --comment-template 'tag={version}'     # tag=v3.5.3
+	| --comment-template legacy              # actions/checkout@v3 | v3.5.3
+
+	Actions whose repo was renamed are warned about, use --fix-renamed to
+	rewrite them to the new name. Workflow files are updated in place, use
+	--dry-run or --diff to print the changes instead.
+
 	e.g.:
 
 	workflow.yaml - before
@@ -70,10 +89,10 @@ var pinCmd = &cobra.Command{
 	|     runs-on: ubuntu-latest
 	|     steps:
 	|       - name: Checkout code
-	|         uses: actions/checkout@93ea575cb5d8a053eaa0ac8fa3b40d7e05a33cc8 # actions/checkout@v3.1.0
+	|         uses: actions/checkout@93ea575cb5d8a053eaa0ac8fa3b40d7e05a33cc8 # v3.1.0
 	| 
 	|       - name: Set up Go
-	|         uses: actions/setup-go@bfdd3570ce990073878bf10f6b2d79082de49492 # actions/setup-go@v2 | v2.2.0
+	|         uses: actions/setup-go@bfdd3570ce990073878bf10f6b2d79082de49492 # v2.2.0 | v2
 	|         with:
 	|           go-version: 1.17
 
@@ -86,48 +105,95 @@ var pinCmd = &cobra.Command{
 
 func init() {
 	pinCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "Print the changes without updating the workflow files")
+	pinCmd.Flags().BoolVar(&showDiff, "diff", false, "Print a unified diff of the changes without updating the workflow files")
+	pinCmd.Flags().StringVar(&actions.CommentTemplate, "comment-template", actions.DefaultCommentTemplate, "Comment written next to pinned actions, with {version}, {ref}, {action} and {refs} replaced, or legacy")
+	pinCmd.Flags().BoolVar(&actions.MigrateComments, "migrate-comments", false, "Rewrite the comments of already pinned actions to the comment template")
+	pinCmd.Flags().BoolVar(&actions.FixRenamed, "fix-renamed", false, "Rewrite actions whose repo was renamed or transferred to the name it lives under now")
 }
 
 func PinWorkflows(cmd *cobra.Command) error {
-	workflows, err := os.ReadDir(workflowDir)
-	if os.IsNotExist(err) {
-		return fmt.Errorf("no workflows found in %s", workflowDir)
-	} else if err != nil {
+	offline := false
+	actions.ResolveSignatures = true
+	actions.PrefetchWorkflows()
+	pinned := []*actions.PinnedAction{}
+	err := rewriteWorkflows(cmd, func(file *actions.UsesFile) error {
+		pinnedActions, err := actions.PinWorkflow(file, offline)
+		pinned = append(pinned, pinnedActions...)
 		return err
+	})
+	if err != nil || dryRun || showDiff {
+		return err
+	}
+	for _, pinnedAction := range pinned {
+		cmd.Println(pinnedAction)
+	}
+	return nil
+}
+
+// rewriteWorkflows runs rewrite on every workflow and action manifest.
+// rewrite writes its result to <file>.tmp, which replaces the file once
+// every file has been rewritten successfully, or is printed and discarded on
+// a dry run. With --diff only the changes are printed, as a unified diff.
+func rewriteWorkflows(cmd *cobra.Command, rewrite func(*actions.UsesFile) error) error {
+	err := actions.ValidateCommentTemplate(actions.CommentTemplate)
+	if err != nil {
+		return err
+	}
+
+	files, err := actions.FindUsesFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return actions.NoUsesFilesError()
 	}
 
 	errFlag := false
 
-	for _, workflow := range workflows {
-		workflowName := workflow.Name()
-		isYAML := strings.HasSuffix(workflowName, ".yml") || strings.HasSuffix(workflowName, ".yaml")
-		if !workflow.IsDir() && isYAML {
-			err = actions.PinWorkflow(workflow.Name())
-			if err != nil {
-				errFlag = true
-				break
-			}
+	for _, file := range files {
+		err = rewrite(file)
+		if err != nil {
+			errFlag = true
+			break
 		}
 	}
-	for _, workflow := range workflows {
-		srcFile := fmt.Sprintf("%s/%s", workflowDir, workflow.Name())
-		tmpFile := fmt.Sprintf("%s/%s.tmp", workflowDir, workflow.Name())
+	for _, file := range files {
+		srcFile := file.Path
+		tmpFile := fmt.Sprintf("%s.tmp", file.Path)
 		if errFlag {
 			os.Remove(tmpFile)
 		} else {
-			if !workflow.IsDir() {
-				if dryRun {
-					cmd.Printf("Pinned %s\n", srcFile)
-					file, err := os.ReadFile(tmpFile)
-					if err == nil {
-						cmd.Println(string(file) + "\n")
-					}
-					os.Remove(tmpFile)
-				} else {
-					os.Rename(tmpFile, srcFile)
+			if showDiff {
+				if diffErr := printDiff(cmd, srcFile, tmpFile); diffErr != nil {
+					err = diffErr
 				}
+				os.Remove(tmpFile)
+			} else if dryRun {
+				cmd.Printf("Pinned %s\n", srcFile)
+				content, err := os.ReadFile(tmpFile)
+				if err == nil {
+					cmd.Println(string(content) + "\n")
+				}
+				os.Remove(tmpFile)
+			} else {
+				os.Rename(tmpFile, srcFile)
 			}
 		}
 	}
 	return err
 }
+
+// printDiff prints the unified diff of srcFile and its rewrite tmpFile to
+// the output of cmd.
+func printDiff(cmd *cobra.Command, srcFile string, tmpFile string) error {
+	srcContent, err := os.ReadFile(srcFile)
+	if err != nil {
+		return err
+	}
+	tmpContent, err := os.ReadFile(tmpFile)
+	if err != nil {
+		return err
+	}
+	utils.PrintDiff(cmd.OutOrStdout(), fmt.Sprintf("a/%s", srcFile), fmt.Sprintf("b/%s", srcFile), srcContent, tmpContent)
+	return nil
+}
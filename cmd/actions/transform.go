@@ -0,0 +1,45 @@
+/*
+Copyright © 2023 Koalalab Inc <dev@koalalab.com>
+*/
+package actions
+
+import (
+	"github.com/koalalab-inc/pinny/pkg/actions"
+	"github.com/spf13/cobra"
+)
+
+var transformCmd = &cobra.Command{
+	Use:   "transform",
+	Short: "Pin all third party Github Actions used in your workflows using lock file only",
+	Long: `
+	Pin all third party Github Actions used in your workflows using lock file only
+
+	Similar to pin command, but uses lock file only to pin Github Actions and
+	docker images. The Github API is never called.
+
+	Throws an error if lock file is not present or an action is missing from it.
+	To generate a lock file, Use: 
+	|> pinny actions lock
+
+	Example:
+		pinny actions transform
+		pinny actions transform --dry-run
+
+	See help for pin command for more details.
+	> pinny actions pin --help
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		offline := true
+		err := rewriteWorkflows(cmd, func(file *actions.UsesFile) error {
+			_, err := actions.PinWorkflow(file, offline)
+			return err
+		})
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	transformCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "Print the changes without updating the workflow files")
+	transformCmd.Flags().StringVar(&actions.CommentTemplate, "comment-template", actions.DefaultCommentTemplate, "Comment written next to pinned actions, with {version}, {ref}, {action} and {refs} replaced, or legacy")
+	transformCmd.Flags().BoolVar(&actions.MigrateComments, "migrate-comments", false, "Rewrite the comments of already pinned actions to the comment template")
+}
@@ -0,0 +1,47 @@
+/*
+Copyright © 2023 Koalalab Inc <dev@koalalab.com>
+*/
+package actions
+
+import (
+	"github.com/koalalab-inc/pinny/pkg/actions"
+	"github.com/spf13/cobra"
+)
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin",
+	Short: "Restore the refs of the Github Actions pinned by pinny",
+	Long: `
+	Restore the refs of the Github Actions pinned by pinny
+
+	Reverts pinny actions pin. Every action pinned to a commit SHA gets the
+	ref named in the trailing comment pinny wrote next to it, and the comment
+	is removed:
+	| uses: actions/checkout@93ea575cb5d8a053eaa0ac8fa3b40d7e05a33cc8 # v3.1.0
+	becomes
+	| uses: actions/checkout@v3.1.0
+
+	Both the version comments, like # v3.1.0, and the legacy comments, like
+	# actions/checkout@v3 | v3.1.0, are understood. Job container and
+	service images and docker:// actions get the image named in their comment
+	back, and the base images of Dockerfiles of docker container actions are
+	restored like pinny docker unpin does. Actions and images without a
+	comment naming their ref are left pinned, with a warning for actions.
+
+	The Github API is never called.
+
+	Example:
+		pinny actions unpin
+		pinny actions unpin --dry-run
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := rewriteWorkflows(cmd, func(file *actions.UsesFile) error {
+			return actions.UnpinWorkflow(file)
+		})
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	unpinCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "Print the changes without updating the workflow files")
+}
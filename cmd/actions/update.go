@@ -0,0 +1,65 @@
+/*
+Copyright © 2023 Koalalab Inc <dev@koalalab.com>
+*/
+package actions
+
+import (
+	"github.com/koalalab-inc/pinny/pkg/actions"
+	"github.com/spf13/cobra"
+)
+
+var updatePolicy string
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update pinned Github Actions to newer releases",
+	Long: `
+	Update the Github Actions pinned by pinny to newer releases. The release
+	a pinned action tracks is read from the trailing comment written by
+	pinny actions pin, both the digest and the comment are updated. Comments
+	are written with --comment-template, see pinny actions pin --help.
+
+	The --policy flag controls how far an action may be moved:
+	| patch  v3.5.1 -> v3.5.3
+	| minor  v3.5.1 -> v3.6.0 (default)
+	| major  v3.5.1 -> v4.1.1
+
+	Only tags written the same way as the tracked release are considered,
+	an action pinned to v3, like # actions/checkout@v3 | v3.6.0, moves to v4
+	with the major policy and is left alone with the patch and minor
+	policies. The ref an action was pinned from is tracked over the other
+	refs the comment names.
+
+	e.g.:
+	|> pinny actions update --policy major
+	| .github/workflows/build.yaml:14 actions/checkout v3.6.0 -> v4.1.1 (f43a0e5 -> b4ffde6)
+
+	workflow.yaml - before
+	|       - name: Checkout code
+	|         uses: actions/checkout@f43a0e5ff2bd294095638e18286ca9a3d1956744 # v3.6.0
+
+	workflow.yaml - after
+	|       - name: Checkout code
+	|         uses: actions/checkout@b4ffde65f46336ab88eb53be808477a3936bae11 # v4.1.1
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		bumps := []*actions.Bump{}
+		err := rewriteWorkflows(cmd, func(file *actions.UsesFile) error {
+			workflowBumps, err := actions.UpdateWorkflow(file, updatePolicy)
+			bumps = append(bumps, workflowBumps...)
+			return err
+		})
+		cobra.CheckErr(err)
+		for _, bump := range bumps {
+			cmd.Printf("%s:%d %s %s -> %s (%.7s -> %.7s)\n", bump.File, bump.Line, bump.Action, bump.From, bump.To, bump.FromDigest, bump.ToDigest)
+		}
+		cmd.Printf("%d actions updated\n", len(bumps))
+	},
+}
+
+func init() {
+	updateCmd.Flags().StringVarP(&updatePolicy, "policy", "p", actions.UpdateMinor, "Semver policy for updates: patch, minor or major")
+	updateCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "Print the changes without updating the workflow files")
+	updateCmd.Flags().StringVar(&actions.CommentTemplate, "comment-template", actions.DefaultCommentTemplate, "Comment written next to pinned actions, with {version}, {ref}, {action} and {refs} replaced, or legacy")
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2023 Koalalab Inc <dev@koalalab.com>
+*/
+package cmd
+
+import (
+	"time"
+
+	"github.com/koalalab-inc/pinny/pkg/actions"
+	"github.com/spf13/cobra"
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Inspect the Github credentials used by pinny",
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which Github credentials are used and the rate limit left",
+	Long: `
+	Show where the Github token used for every configured host comes from,
+	the scopes granted to it and the rate limit left.
+
+	Tokens are looked up in this order:
+	| GITHUB_TOKEN or GH_TOKEN for github.com
+	| GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN for Github Enterprise Server,
+	|   then GITHUB_TOKEN or GH_TOKEN if it is the --github-api-url host
+	| GITHUB_COM_TOKEN for github.com when it is a --github-host only
+	| a Github App installation, configured through
+	|   PINNY_GITHUB_APP_ID, PINNY_GITHUB_APP_PRIVATE_KEY_FILE
+	|   (or PINNY_GITHUB_APP_PRIVATE_KEY) and PINNY_GITHUB_APP_INSTALLATION_ID,
+	|   used on the PINNY_GITHUB_APP_HOST host, or the --github-api-url host
+	|   when that is not set, and renewed before it expires
+	| the hosts config of the gh CLI (~/.config/gh/hosts.yml)
+	| ~/.netrc
+
+	e.g.:
+	|> pinny auth status
+	| github.com
+	|   Token source: /home/me/.config/gh/hosts.yml
+	|   Scopes:       gist, read:org, repo
+	|   Rate limit:   4987/5000 remaining, resets at 13:25:30
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		statuses, err := actions.AuthStatus()
+		cobra.CheckErr(err)
+		for _, status := range statuses {
+			cmd.Println(status.Host)
+			cmd.Printf("  Token source: %s\n", status.Source)
+			scopes := status.Scopes
+			if scopes == "" {
+				scopes = "none reported"
+			}
+			cmd.Printf("  Scopes:       %s\n", scopes)
+			rate := status.RateLimit
+			cmd.Printf("  Rate limit:   %d/%d remaining, resets at %s\n", rate.Remaining, rate.Limit, rate.Reset.Format(time.TimeOnly))
+		}
+	},
+}
+
+func init() {
+	authCmd.AddCommand(authStatusCmd)
+	rootCmd.AddCommand(authCmd)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2023 Koalalab Inc <dev@koalalab.com>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/koalalab-inc/pinny/pkg/cache"
+	"github.com/spf13/cobra"
+)
+
+var cacheKinds = []string{cache.KindImage, cache.KindGithub}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and clear the cache of Github API responses and images",
+	Long: `
+	Github API responses and image digests are cached in
+	$XDG_CACHE_HOME/pinny (~/.cache/pinny on Linux), so running pinny again,
+	e.g. in a pre-commit hook, needs next to no requests.
+
+	A cached result is used for --cache-ttl, 24h by default. Actions are
+	resolved from the cached responses on every run. Responses listing refs
+	or comparing commits are revalidated every time, so moved tags and
+	impostor commits are always caught. Expired and revalidated responses
+	are fetched with a conditional request, which does not count against the
+	rate limit. Use --no-cache to bypass the cache for a single run.
+`,
+}
+
+var cacheLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the cached images and Github API responses",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := cache.Dir()
+		cobra.CheckErr(err)
+		entries, err := cache.List()
+		cobra.CheckErr(err)
+
+		cmd.Printf("Cache directory: %s\n\n", dir)
+		w := tabwriter.NewWriter(cmd.OutOrStderr(), 0, 4, 2, ' ', 0)
+		for i, entry := range entries {
+			// a line without cells ends the column block, so every kind
+			// gets columns of its own width
+			if i > 0 && entry.Kind != entries[i-1].Kind {
+				fmt.Fprintln(w)
+			}
+			expires := fmt.Sprintf("expires in %s", time.Until(entry.Expiry()).Round(time.Minute))
+			if entry.Expired() {
+				expires = "expired"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Kind, entry.Key, cachedDigest(entry), expires)
+		}
+		w.Flush()
+		cmd.Printf("\n%d entries\n", len(entries))
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:       "clear [image|github]",
+	Short:     "Remove all cached entries, or only those of one kind",
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: cacheKinds,
+	Run: func(cmd *cobra.Command, args []string) {
+		kind := ""
+		if len(args) > 0 {
+			kind = args[0]
+			if !slices.Contains(cacheKinds, kind) {
+				cobra.CheckErr(fmt.Errorf("invalid kind %q, expected one of image or github", kind))
+			}
+		}
+		removed, err := cache.Clear(kind)
+		cobra.CheckErr(err)
+		cmd.Printf("Removed %d cache entries\n", removed)
+	},
+}
+
+// cachedDigest returns the digest an image entry resolved to.
+func cachedDigest(entry *cache.Entry) string {
+	if entry.Kind != cache.KindImage {
+		return "-"
+	}
+	var digest string
+	json.Unmarshal(entry.Value, &digest)
+	return digest
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheLsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2023 Koalalab Inc <dev@koalalab.com>
+*/
+package cmd
+
+import (
+	"os"
+
+	"github.com/koalalab-inc/pinny/pkg/actions"
+	"github.com/koalalab-inc/pinny/pkg/docker"
+	"github.com/koalalab-inc/pinny/pkg/findings"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Fail if any Github Action or Docker image is not pinned",
+	Long: `
+	Check that every Github Action used in your workflows, composite actions,
+	workflow templates and action.yml, and every base image used in your
+	Dockerfiles is pinned. No files are modified and no network access is
+	needed. Use --path to check other locations, Dockerfiles are then only
+	looked for there.
+
+	The command exits with a non-zero status if any of these are found:
+	| uses: references that are not full 40 character commit SHAs
+	|   (tags, branches and short SHAs)
+	| uses: docker:// references without a digest
+	| job container and service images without a digest
+	| FROM lines that reference an image by tag only
+	| references that break the rules of a .pinny.yaml policy file
+
+	A .pinny.yaml file in the repository root holds the policy pin, check and
+	audit enforce, see the README:
+	| actions:
+	|   trusted:
+	|     - koalalab-inc/*
+	|   deny:
+	|     - tj-actions/changed-files
+	|   forbid-branch-refs: true
+	| images:
+	|   require-digest:
+	|     - ghcr.io
+	|   forbid-latest: true
+
+	Every offender is reported with its file and line, e.g.:
+	|> pinny check
+	| .github/workflows/build.yaml:14: actions/checkout@v3: mutable ref, pin to a full commit SHA
+	| Dockerfile:1: golang:alpine: image is not pinned to a digest
+	| 2 unpinned references found
+
+	Use it in CI to keep unpinned references from being merged.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		results, err := actions.CheckWorkflows()
+		cobra.CheckErr(err)
+
+		// Dockerfiles are searched for in the whole repo unless --path
+		// names where to look
+		roots := actions.Paths
+		if len(roots) == 0 {
+			roots = []string{"."}
+		}
+		dockerfiles, err := docker.FindDockerfiles(roots...)
+		cobra.CheckErr(err)
+		for _, dockerfile := range dockerfiles {
+			dockerfileResults, err := docker.CheckDockerfile(dockerfile)
+			cobra.CheckErr(err)
+			results = append(results, dockerfileResults...)
+		}
+
+		if len(results) == 0 {
+			cmd.Println("All references are pinned")
+			return
+		}
+		printFindings(cmd, results)
+		violations := 0
+		for _, finding := range results {
+			if finding.Rule != "" {
+				violations++
+			}
+		}
+		if unpinned := len(results) - violations; unpinned > 0 {
+			cmd.Printf("%d unpinned references found\n", unpinned)
+		}
+		if violations > 0 {
+			cmd.Printf("%d policy violations found\n", violations)
+			os.Exit(findings.ExitPolicy)
+		}
+		os.Exit(findings.ExitUnpinned)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().StringSliceVar(&actions.Paths, "path", nil, "Workflow, action and Dockerfile files and directories to check instead of the default locations")
+}
+
+func printFindings(cmd *cobra.Command, results []*findings.Finding) {
+	for _, finding := range results {
+		cmd.Println(finding.String())
+	}
+}
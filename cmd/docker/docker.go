@@ -4,6 +4,10 @@ Copyright © 2023 Koalalab Inc <dev@koalalab.com>
 package docker
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/koalalab-inc/pinny/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -29,6 +33,9 @@ Description:
 var dockerfile string
 var inplace bool
 
+// showDiff prints a unified diff of the Dockerfile instead of writing it
+var showDiff bool
+
 var DockerCmd = &cobra.Command{
 	Use:   "docker",
 	Short: "\nHash-pining for your third party Docker images",
@@ -40,9 +47,41 @@ func init() {
 		transformCmd,
 		digestCmd,
 		lockCmd,
+		unpinCmd,
 	}
 	for _, cmd := range commands {
 		cmd.SetHelpTemplate(dockerHelpTemplate)
 		DockerCmd.AddCommand(cmd)
 	}
 }
+
+// writeDockerfile moves <dockerfile>.<suffix>.tmp, the rewrite of the
+// Dockerfile, to <dockerfile>.<suffix>, or over the Dockerfile with
+// --inplace. With --diff the changes are printed to the output of cmd as a
+// unified diff and nothing is written. The rewrite is discarded if err is set.
+func writeDockerfile(cmd *cobra.Command, suffix string, err error) error {
+	srcFile := dockerfile
+	tmpFile := fmt.Sprintf("%s.%s.tmp", dockerfile, suffix)
+	outFile := fmt.Sprintf("%s.%s", dockerfile, suffix)
+	if inplace {
+		outFile = srcFile
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	if showDiff {
+		defer os.Remove(tmpFile)
+		srcContent, err := os.ReadFile(srcFile)
+		if err != nil {
+			return err
+		}
+		tmpContent, err := os.ReadFile(tmpFile)
+		if err != nil {
+			return err
+		}
+		utils.PrintDiff(cmd.OutOrStdout(), fmt.Sprintf("a/%s", srcFile), fmt.Sprintf("b/%s", outFile), srcContent, tmpContent)
+		return nil
+	}
+	return os.Rename(tmpFile, outFile)
+}
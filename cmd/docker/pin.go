@@ -4,9 +4,6 @@ Copyright © 2023 Koalalab Inc <dev@koalalab.com>
 package docker
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/koalalab-inc/pinny/pkg/docker"
 	"github.com/spf13/cobra"
 )
@@ -32,6 +29,11 @@ var pinCmd = &cobra.Command{
 	To update the Dockerfile in place, use -i flag:
 	|> pinny docker pin -i
 
+	To see what would change without writing anything, use --diff. A unified
+	diff of the lines that change is printed, coloured when stdout is a
+	terminal:
+	|> pinny docker pin --diff
+
 	Dockerfile - before
 	| FROM golang:alpine AS builder
 	| WORKDIR /app
@@ -60,22 +62,12 @@ var pinCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		offline := false
 		err := docker.GeneratePinnedDockerfile(dockerfile, offline)
-		srcFile := dockerfile
-		tmpFile := fmt.Sprintf("%s.pinned.tmp", dockerfile)
-		outFile := fmt.Sprintf("%s.pinned", dockerfile)
-		if inplace {
-			outFile = srcFile
-		}
-		if err != nil {
-			os.Remove(tmpFile)
-		} else {
-			err = os.Rename(tmpFile, outFile)
-		}
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeDockerfile(cmd, "pinned", err))
 	},
 }
 
 func init() {
 	pinCmd.Flags().BoolVarP(&inplace, "inplace", "i", false, "Update the Dockerfile in place")
+	pinCmd.Flags().BoolVar(&showDiff, "diff", false, "Print a unified diff of the changes without writing a Dockerfile")
 	pinCmd.Flags().StringVarP(&dockerfile, "dockerfile", "f", "Dockerfile", "Dockerfile to use")
 }
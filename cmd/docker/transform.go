@@ -4,9 +4,6 @@ Copyright © 2023 Koalalab Inc <dev@koalalab.com>
 package docker
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/koalalab-inc/pinny/pkg/docker"
 	"github.com/spf13/cobra"
 )
@@ -27,6 +24,7 @@ var transformCmd = &cobra.Command{
 		pinny docker transform
 		pinny docker transform [-f Dockerfile]
 		pinny docker transform -f DevDockerfile
+		pinny docker transform --diff
 
 	See help for pin command for more details.
 	> pinny docker pin --help
@@ -34,23 +32,13 @@ var transformCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		offline := true
 		err := docker.GeneratePinnedDockerfile(dockerfile, offline)
-		srcFile := dockerfile
-		tmpFile := fmt.Sprintf("%s.pinned.tmp", dockerfile)
-		outFile := fmt.Sprintf("%s.pinned", dockerfile)
-		if inplace {
-			outFile = srcFile
-		}
-		if err != nil {
-			os.Remove(tmpFile)
-		} else {
-			err = os.Rename(tmpFile, outFile)
-		}
-		cobra.CheckErr(err)
+		cobra.CheckErr(writeDockerfile(cmd, "pinned", err))
 	},
 }
 
 func init() {
 	transformCmd.Flags().StringVarP(&dockerfile, "dockerfile", "f", "Dockerfile", "Dockerfile to use")
 	transformCmd.Flags().BoolVarP(&inplace, "inplace", "i", false, "Update the Dockerfile in place")
+	transformCmd.Flags().BoolVar(&showDiff, "diff", false, "Print a unified diff of the changes without writing a Dockerfile")
 
 }
@@ -0,0 +1,45 @@
+/*
+Copyright © 2023 Koalalab Inc <dev@koalalab.com>
+*/
+package docker
+
+import (
+	"github.com/koalalab-inc/pinny/pkg/docker"
+	"github.com/spf13/cobra"
+)
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin",
+	Short: "Restore the tags of the Docker images pinned by pinny",
+	Long: `
+	Restore the tags of the Docker images pinned by pinny
+
+	Reverts pinny docker pin. Every FROM preceded by the comment pinny writes
+	when pinning gets the image named in the comment back, and the comment is
+	removed:
+	| # Pinned golang:1.21 using pinny
+	| FROM golang@sha256:110b07af87238fbdc5f1df52b00927cf58ce3de358eeeb1854f10a8b5e5e1411 AS builder
+	becomes
+	| FROM golang:1.21 AS builder
+
+	FROM commands without such a comment are left as they are.
+
+	By default a <Dockerfile>.unpinned file is created. To update the
+	Dockerfile in place, use -i flag:
+	|> pinny docker unpin -i
+
+	Example:
+		pinny docker unpin
+		pinny docker unpin [-f Dockerfile]
+		pinny docker unpin -f DevDockerfile -i
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := docker.GenerateUnpinnedDockerfile(dockerfile)
+		cobra.CheckErr(writeDockerfile(cmd, "unpinned", err))
+	},
+}
+
+func init() {
+	unpinCmd.Flags().StringVarP(&dockerfile, "dockerfile", "f", "Dockerfile", "Dockerfile to use")
+	unpinCmd.Flags().BoolVarP(&inplace, "inplace", "i", false, "Update the Dockerfile in place")
+}
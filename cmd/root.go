@@ -4,14 +4,22 @@ Copyright © 2023 Koalalab Inc <dev@koalalab.com>
 package cmd
 
 import (
+	"os"
+
 	"github.com/koalalab-inc/pinny/cmd/actions"
 	"github.com/koalalab-inc/pinny/cmd/docker"
+	pkgactions "github.com/koalalab-inc/pinny/pkg/actions"
+	"github.com/koalalab-inc/pinny/pkg/cache"
+	"github.com/koalalab-inc/pinny/pkg/utils"
 
 	"github.com/spf13/cobra"
 )
 
 var version string
 
+var githubAPIURL string
+var githubHosts map[string]string
+
 var rootCmd = NewRootCmd()
 
 func NewRootCmd() *cobra.Command {
@@ -19,6 +27,9 @@ func NewRootCmd() *cobra.Command {
 		Use:     "pinny",
 		Short:   "\nHash-pining for your OSS dependencies",
 		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return pkgactions.ConfigureGithubHosts(githubAPIURL, githubHosts)
+		},
 	}
 }
 
@@ -30,6 +41,11 @@ func Execute() {
 func init() {
 	rootCmd.SetVersionTemplate("Pinny v{{.Version}}\n")
 	rootCmd.CompletionOptions.HiddenDefaultCmd = true
+	rootCmd.PersistentFlags().IntVarP(&utils.Jobs, "jobs", "j", utils.Jobs, "Number of actions and images to resolve at the same time")
+	rootCmd.PersistentFlags().BoolVar(&cache.Disabled, "no-cache", false, "Resolve every action and image again instead of using the cache")
+	rootCmd.PersistentFlags().DurationVar(&cache.TTL, "cache-ttl", cache.TTL, "How long Github API responses and images are cached")
+	rootCmd.PersistentFlags().StringVar(&githubAPIURL, "github-api-url", os.Getenv("GITHUB_API_URL"), "Github API URL to resolve actions against, e.g. https://github.example.com/api/v3")
+	rootCmd.PersistentFlags().StringToStringVar(&githubHosts, "github-host", map[string]string{}, "Route the actions of an owner to another Github API, e.g. actions=https://api.github.com")
 	rootCmd.AddCommand(docker.DockerCmd)
 	rootCmd.AddCommand(actions.ActionsCmd)
 }
@@ -1,15 +1,16 @@
 package actions
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/koalalab-inc/pinny/pkg/docker"
+	"github.com/koalalab-inc/pinny/pkg/policy"
 	"github.com/koalalab-inc/pinny/pkg/utils"
 
 	"github.com/google/go-github/v56/github"
@@ -17,25 +18,33 @@ import (
 
 const workflowDir = ".github/workflows"
 
-var actionRefCache = make(map[string]*GithubActionRef)
-
-func getTokenFromEnv() *string {
-	token, exists := os.LookupEnv("GITHUB_TOKEN")
-	token = strings.TrimSpace(token)
-	if !exists || token == "" {
-		return nil
-	}
-	return &token
+// resolvedActionRef is a resolved action ref along with the warnings raised
+// while resolving it, which are printed the first time it is used.
+type resolvedActionRef struct {
+	githubActionRef *GithubActionRef
+	warnings        utils.Warnings
+	warnOnce        sync.Once
 }
 
-func getGithubClient(token *string) *github.Client {
-	client := github.NewClient(nil)
-	if token != nil {
-		return client.WithAuthToken(*token)
-	}
-	return client
+func (r *resolvedActionRef) printWarnings() {
+	r.warnOnce.Do(r.warnings.Print)
 }
 
+var actionRefCache utils.Memo[*resolvedActionRef]
+
+// ResolveSignatures looks up the signature of the tag or commit every action
+// resolves to, which takes another request per action. It is set by pin and
+// audit, which report the signatures.
+var ResolveSignatures bool
+
+var repoRefsCache utils.Memo[[]*github.Reference]
+
+// tagCache maps the SHA of annotated tag objects to the tag objects.
+var tagCache utils.Memo[*github.Tag]
+
+// refContainsCache caches comparisons, keyed by owner/repo/base...target.
+var refContainsCache utils.Memo[bool]
+
 type GithubActionRef struct {
 	Raw           string
 	Digest        string
@@ -43,7 +52,15 @@ type GithubActionRef struct {
 	Repo          string
 	Path          string
 	Ref           string
+	RefType       string
 	OtherRefNames []string
+	Reachability  string
+	// Canonical is the owner/repo the repo was renamed or transferred to,
+	// empty if it still lives under Owner/Repo
+	Canonical string
+	// Signature is the signature of the tag or commit the ref resolved to,
+	// nil if it was not resolved against Github
+	Signature *Signature
 }
 
 func (g *GithubActionRef) NameWithRef() string {
@@ -79,50 +96,162 @@ func parseActionString(actionString string) (*GithubActionRef, error) {
 	}
 }
 
-func getActionDigest(owner string, repo string, ref string) (*string, []*github.Reference, error) {
-	token := getTokenFromEnv()
-	client := getGithubClient(token)
-	ctx := context.Background()
-	tagRef := fmt.Sprintf("tags/%s", ref)
-	branchRef := fmt.Sprintf("heads/%s", ref)
-	var digest string
+// listRepoRefs returns every tag and branch of owner/repo. Listing a big
+// repo takes many requests, so it is only done when a ref cannot be looked
+// up by name, like a short SHA, or every ref is needed, like for impostor
+// checks. The listing is cached per repo.
+func listRepoRefs(ctx context.Context, client *github.Client, owner string, repo string) ([]*github.Reference, error) {
+	return listMatchingRefs(ctx, client, owner, repo, "")
+}
 
+// listMatchingRefs returns the refs of owner/repo starting with refs/<prefix>.
+// Once every ref of the repo has been listed, it is served from that listing.
+func listMatchingRefs(ctx context.Context, client *github.Client, owner string, repo string, prefix string) ([]*github.Reference, error) {
+	if refs, ok := repoRefsCache.Get(fmt.Sprintf("%s/%s", owner, repo)); ok {
+		matching := []*github.Reference{}
+		for _, r := range refs {
+			if strings.HasPrefix(r.GetRef(), fmt.Sprintf("refs/%s", prefix)) {
+				matching = append(matching, r)
+			}
+		}
+		return matching, nil
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s/%s", owner, repo, prefix)
+	if prefix == "" {
+		cacheKey = fmt.Sprintf("%s/%s", owner, repo)
+	}
+	return repoRefsCache.Do(cacheKey, func() ([]*github.Reference, error) {
+		return fetchMatchingRefs(ctx, client, owner, repo, prefix)
+	})
+}
+
+func fetchMatchingRefs(ctx context.Context, client *github.Client, owner string, repo string, prefix string) ([]*github.Reference, error) {
 	opts := &github.ReferenceListOptions{
-		Ref: "",
+		Ref: prefix,
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	refs := []*github.Reference{}
+	for {
+		page, resp, err := rateLimited(ctx, func() ([]*github.Reference, *github.Response, error) {
+			return client.Git.ListMatchingRefs(ctx, owner, repo, opts)
+		})
+		if err != nil {
+			return nil, err
+		}
+		recordRedirect(owner, repo, resp)
+		refs = append(refs, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return refs, nil
+}
+
+// findRef looks up the tag or branch named ref along with its type. A tag
+// takes precedence over a branch of the same name, like it does in git. The
+// refs fetched along with it are returned as well, these are the refs it is
+// a prefix of, e.g. the tag v3.5.3 for v3.
+func findRef(ctx context.Context, client *github.Client, owner string, repo string, ref string) (*github.Reference, string, []*github.Reference, error) {
+	for _, kind := range []struct{ prefix, refType string }{{"tags/", "tag"}, {"heads/", "branch"}} {
+		refs, err := listMatchingRefs(ctx, client, owner, repo, kind.prefix+ref)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		for _, r := range refs {
+			if r.GetRef() == fmt.Sprintf("refs/%s%s", kind.prefix, ref) {
+				return r, kind.refType, refs, nil
+			}
+		}
 	}
+	return nil, "", nil, nil
+}
 
-	refs, _, err := client.Git.ListMatchingRefs(ctx, owner, repo, opts)
+func getTag(ctx context.Context, client *github.Client, owner string, repo string, sha string) (*github.Tag, error) {
+	cacheKey := fmt.Sprintf("%s/%s/%s", owner, repo, sha)
+	return tagCache.Do(cacheKey, func() (*github.Tag, error) {
+		tag, _, err := rateLimited(ctx, func() (*github.Tag, *github.Response, error) {
+			return client.Git.GetTag(ctx, owner, repo, sha)
+		})
+		return tag, err
+	})
+}
+
+// dereference returns the SHA of the commit a ref points to, peeling
+// annotated tags.
+func dereference(ctx context.Context, client *github.Client, owner string, repo string, r *github.Reference) (string, error) {
+	if r.GetObject().GetType() == "tag" {
+		tag, err := getTag(ctx, client, owner, repo, r.GetObject().GetSHA())
+		if err != nil {
+			return "", err
+		}
+		return tag.GetObject().GetSHA(), nil
+	}
+	return r.GetObject().GetSHA(), nil
+}
+
+// resolveCanonical records the name the repo of githubActionRef was renamed
+// or transferred to, which Github redirected the requests made so far to.
+func resolveCanonical(ctx context.Context, client *github.Client, githubActionRef *GithubActionRef, warnings *utils.Warnings) error {
+	canonical, err := canonicalName(ctx, client, githubActionRef.Owner, githubActionRef.Repo)
 	if err != nil {
-		return nil, nil, err
+		return err
+	}
+	githubActionRef.Canonical = canonical
+	if canonical != "" {
+		warnings.Warnf("%s\n", renamedWarning(githubActionRef))
 	}
+	return nil
+}
 
+// resolveGithubActionRef resolves the ref of githubActionRef to the digest
+// of a commit and records the type of the ref, the names of other refs
+// pointing to the same object and whether the commit is reachable upstream.
+// Warnings are collected in warnings instead of being printed.
+func resolveGithubActionRef(githubActionRef *GithubActionRef, warnings *utils.Warnings) error {
+	ctx := context.Background()
+	owner := githubActionRef.Owner
+	repo := githubActionRef.Repo
+	ref := githubActionRef.Ref
+	client, err := getGithubClient(owner)
+	if err != nil {
+		return err
+	}
+
+	// A full SHA can only name a commit, everything else is looked up by
+	// name first, even if it looks like a short SHA
 	var exactRef *github.Reference
 	var exactRefType string
-	for _, ref := range refs {
-		// Check for exact tag match
-		if ref.GetRef() == fmt.Sprintf("refs/%s", tagRef) {
-			exactRef = ref
-			exactRefType = "tag"
-			break
-		} else if ref.GetRef() == fmt.Sprintf("refs/%s", branchRef) {
-			exactRef = ref
-			exactRefType = "branch"
-			break
+	var refs []*github.Reference
+	if !isFullSHA(ref) {
+		exactRef, exactRefType, refs, err = findRef(ctx, client, owner, repo, ref)
+		if err != nil {
+			return missingRepoError(ctx, client, owner, repo, err)
 		}
 	}
 
 	if exactRefType == "branch" {
-		fmt.Printf("WARN:: Branch references are being used for third party Github Action: %s/%s@%s\n", owner, repo, ref)
+		warnings.Warnf("Branch references are being used for third party Github Action: %s/%s@%s\n", owner, repo, ref)
 	}
 
-	// Check for shortened hash
-	if exactRef == nil {
+	// Check for shortened hash. Commits are not refs, so every ref has to be
+	// listed to find the one the commit is the tip of. Full SHAs are left to
+	// the impostor check, which lists the refs only if it has to
+	if exactRef == nil && !isFullSHA(ref) && shortSHARegex.MatchString(ref) {
+		refs, err = listRepoRefs(ctx, client, owner, repo)
+		if err != nil {
+			return missingRepoError(ctx, client, owner, repo, err)
+		}
 		for _, r := range refs {
 			sha := *r.GetObject().SHA
 			refType := r.GetObject().GetType()
 			if refType == "commit" && strings.HasPrefix(sha, ref) {
 				if sha != ref {
-					fmt.Printf("WARN:: Shortened hash found for ref %s/%s@%s.s\nIt is recommended to use full 40 character hash.", owner, repo, ref)
+					warnings.Warnf("Shortened hash found for ref %s/%s@%s.\nIt is recommended to use full 40 character hash.\n", owner, repo, ref)
 				}
 				exactRef = r
 				break
@@ -130,175 +259,360 @@ func getActionDigest(owner string, repo string, ref string) (*string, []*github.
 		}
 	}
 
+	// only a full SHA names a commit no ref points to, anything else is a
+	// typo or a deleted ref and is not worth spending the impostor budget on
+	if exactRef == nil && !isFullSHA(ref) {
+		return fmt.Errorf("ref %s/%s@%s not found, it is neither a tag, a branch nor a full commit SHA", owner, repo, ref)
+	}
+
 	//check for impostor commits
 	if exactRef == nil {
-		fmt.Printf("WARN:: No exact match found for ref %s/%s@%s\n", owner, repo, ref)
-		impostor := true
-		if exactRefType != "tag" && exactRefType != "branch" {
-			for _, r := range refs {
-				rRef := r.GetRef()
-				if strings.HasPrefix(rRef, "refs/tags/") || strings.HasPrefix(rRef, "refs/heads/") {
-					contained, err := refContains(ctx, client, owner, repo, rRef, ref)
-					if err != nil {
-						return nil, nil, err
-					}
-					if contained {
-						impostor = false
-						break
-					}
-				}
-			}
-			if impostor {
-				fmt.Printf("WARN:: Impostor found for ref %s/%s@%s\n", owner, repo, ref)
-			}
+		warnings.Warnf("No exact match found for ref %s/%s@%s\n", owner, repo, ref)
+		reachability, err := checkImpostor(ctx, client, owner, repo, ref)
+		if err != nil {
+			return missingRepoError(ctx, client, owner, repo, err)
 		}
-		return &ref, []*github.Reference{}, nil
-	}
-	refObjectType := exactRef.GetObject().GetType()
-	if refObjectType == "tag" {
-		tag, _, err := client.Git.GetTag(ctx, owner, repo, *exactRef.GetObject().SHA)
+		err = resolveCanonical(ctx, client, githubActionRef, warnings)
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
-		digest = *tag.GetObject().SHA
-	} else {
-		digest = *exactRef.GetObject().SHA
+		switch reachability {
+		case ReachableForkNetwork:
+			warnings.Warnf("Impostor found for ref %s/%s@%s\n", owner, repo, ref)
+		case ReachableMissing:
+			warnings.Warnf("Ref %s/%s@%s does not exist\n", owner, repo, ref)
+		case ReachableInconclusive:
+			warnings.Warnf("Could not tell whether %s/%s@%s is an impostor commit within %d comparisons, raise --impostor-budget to compare against more refs\n", owner, repo, ref, ImpostorBudget)
+		}
+		if ResolveSignatures && reachability != ReachableMissing {
+			githubActionRef.Signature, err = commitSignature(ctx, client, owner, repo, ref)
+			if err != nil {
+				return err
+			}
+		}
+		githubActionRef.Digest = ref
+		githubActionRef.RefType = "commit"
+		githubActionRef.OtherRefNames = []string{}
+		githubActionRef.Reachability = reachability
+		return nil
 	}
 
-	otherMatchingRefs := []*github.Reference{}
-	if exactRef != nil {
-		for _, r := range refs {
-			if *r.GetObject().SHA == *exactRef.GetObject().SHA && r.GetRef() != exactRef.GetRef() {
-				otherMatchingRefs = append(otherMatchingRefs, r)
+	err = resolveCanonical(ctx, client, githubActionRef, warnings)
+	if err != nil {
+		return err
+	}
+
+	digest, err := dereference(ctx, client, owner, repo, exactRef)
+	if err != nil {
+		return err
+	}
+
+	otherRefNamesArr := []string{}
+	for _, r := range refs {
+		if *r.GetObject().SHA == *exactRef.GetObject().SHA && r.GetRef() != exactRef.GetRef() {
+			refName := r.GetRef()
+			if refNameArr := strings.Split(refName, "/"); len(refNameArr) > 2 {
+				refName = refNameArr[2]
 			}
+			otherRefNamesArr = append(otherRefNamesArr, refName)
+		}
+	}
+
+	if exactRefType == "" {
+		exactRefType = "commit"
+	}
+
+	if ResolveSignatures {
+		githubActionRef.Signature, err = refSignature(ctx, client, owner, repo, exactRef, digest)
+		if err != nil {
+			return err
 		}
 	}
 
-	return &digest, otherMatchingRefs, nil
+	githubActionRef.Digest = digest
+	githubActionRef.RefType = exactRefType
+	githubActionRef.OtherRefNames = otherRefNamesArr
+	githubActionRef.Reachability = ReachableUpstream
+	return nil
 }
 
 func GetDigest(actionString string) (*string, error) {
-	githubActionRef, err := parseActionString(actionString)
+	githubActionRef, err := GetGithubActionRefWithDigest(actionString)
 	if err != nil {
 		return nil, err
 	}
-	owner := githubActionRef.Owner
-	repo := githubActionRef.Repo
-	ref := githubActionRef.Ref
+	return &githubActionRef.Digest, nil
+}
 
-	digest, _, err := getActionDigest(owner, repo, ref)
+// GetGithubActionRefWithDigest resolves actionString. Resolutions are cached
+// and concurrent calls for the same action share a single resolution. The
+// warnings raised while resolving an action are printed on the first call
+// that uses it rather than when it was resolved, so prefetching actions
+// concurrently keeps the warnings in the order the actions are used in.
+func GetGithubActionRefWithDigest(actionString string) (*GithubActionRef, error) {
+	githubActionRef, resolved, err := getResolvedActionRef(actionString)
 	if err != nil {
 		return nil, err
 	}
-
-	githubActionRef.Digest = *digest
-
-	return digest, nil
+	resolved.printWarnings()
+	githubActionRef.Digest = resolved.githubActionRef.Digest
+	githubActionRef.RefType = resolved.githubActionRef.RefType
+	githubActionRef.OtherRefNames = resolved.githubActionRef.OtherRefNames
+	githubActionRef.Reachability = resolved.githubActionRef.Reachability
+	githubActionRef.Canonical = resolved.githubActionRef.Canonical
+	githubActionRef.Signature = resolved.githubActionRef.Signature
+	return githubActionRef, nil
 }
 
-func GetGithubActionRefWithDigest(actionString string) (*GithubActionRef, error) {
+func getResolvedActionRef(actionString string) (*GithubActionRef, *resolvedActionRef, error) {
 	githubActionRef, err := parseActionString(actionString)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	owner := githubActionRef.Owner
 	repo := githubActionRef.Repo
 	ref := githubActionRef.Ref
 
 	cacheKey := fmt.Sprintf("%s/%s@%s", owner, repo, ref)
-	if githubActionRef, ok := actionRefCache[cacheKey]; ok {
-		return githubActionRef, nil
-	}
-
-	digest, matchingRefs, err := getActionDigest(owner, repo, ref)
-	otherRefNamesArr := []string{}
-	for _, ref := range matchingRefs {
-		refName := ref.GetRef()
-		if refNameArr := strings.Split(ref.GetRef(), "/"); len(refNameArr) > 2 {
-			refName = refNameArr[2]
+	resolved, err := actionRefCache.Do(cacheKey, func() (*resolvedActionRef, error) {
+		resolved := &resolvedActionRef{
+			githubActionRef: &GithubActionRef{
+				Owner: owner,
+				Repo:  repo,
+				Ref:   ref,
+			},
 		}
-		otherRefNamesArr = append(otherRefNamesArr, refName)
-	}
-
+		// resolutions are not cached on disk, as tags move and commits
+		// turn out to be impostors. They are made from the cached Github
+		// API responses instead, the ones about refs revalidated every time
+		err := resolveGithubActionRef(resolved.githubActionRef, &resolved.warnings)
+		if err != nil {
+			return nil, err
+		}
+		return resolved, nil
+	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return githubActionRef, resolved, nil
+}
 
-	githubActionRef.Digest = *digest
-	githubActionRef.OtherRefNames = otherRefNamesArr
+// PinWorkflow pins the actions, docker images and job container and service
+// images used in a workflow or action manifest and writes the result to
+// <file>.tmp. When offline is set, digests are taken from the lock file only
+// and the Github API is never called. With MigrateComments set, the comments
+// of pinned actions are rewritten to CommentTemplate, with FixRenamed set,
+// actions whose repo has moved are rewritten to the name it lives under now.
+// The actions pinned are returned, for the caller to report.
+func PinWorkflow(file *UsesFile, offline bool) ([]*PinnedAction, error) {
+	if file.Dockerfile {
+		return nil, pinDockerfile(file, offline)
+	}
 
-	actionRefCache[cacheKey] = githubActionRef
-	return githubActionRef, nil
-}
+	var lock *ActionsLock
+	if offline {
+		var err error
+		lock, err = readLockfile()
+		if err != nil {
+			return nil, err
+		}
+	}
 
-func PinWorkflow(workflowName string) error {
-	workflow, err := os.Open(fmt.Sprintf("%s/%s", workflowDir, workflowName))
+	content, usesNodes, err := file.read()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	tmpWorkflow, err := os.OpenFile(fmt.Sprintf("%s/%s.tmp", workflowDir, workflowName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	p, err := policy.Load()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	tmpWorkflowWriter := bufio.NewWriter(tmpWorkflow)
 
-	defer tmpWorkflow.Close()
-	defer tmpWorkflowWriter.Flush()
-
-	workflowScanner := bufio.NewScanner(workflow)
-
-	usesDockerRegex := regexp.MustCompile(`^(?P<pre>.*uses\s*:\s*)(?P<actionString>docker://\S+)(?P<post>.*)$`)
-	usesActionRegex := regexp.MustCompile(`^(?P<pre>.*uses\s*:\s*)(?P<actionString>\S+@\S+)(?P<post>.*)$`)
-
-	for workflowScanner.Scan() {
-		line := workflowScanner.Text()
-		if strings.Contains(line, "uses:") {
-			var actionString string
-			if ok, matches := utils.MatchNamedRegex(usesDockerRegex, line); ok {
-				actionString = matches["actionString"]
-				dockerImageRef, err := docker.GetImageRefWithDigest(actionString)
-				if err != nil {
-					return err
+	edits := []yamlEdit{}
+	pinned := []*PinnedAction{}
+	for _, node := range usesNodes {
+		actionString := node.Value
+		if strings.HasPrefix(actionString, "docker://") {
+			if violation := p.CheckImage(actionString); violation != nil {
+				return nil, violationError(file.Path, node, violation)
+			}
+			var dockerImageRef *docker.DockerImageRef
+			if offline {
+				dockerImageRef, err = lock.imageRef(actionString)
+			} else {
+				dockerImageRef, err = docker.GetImageRefWithDigest(actionString)
+			}
+			if err != nil {
+				return nil, err
+			}
+			pinnedActionString := fmt.Sprintf("docker://%s", dockerImageRef.OriginalName("digest"))
+			if pinnedActionString == actionString {
+				continue
+			}
+			edits = append(edits, yamlEdit{
+				node:    node,
+				value:   pinnedActionString,
+				comment: dockerImageRef.Raw,
+			})
+		} else if strings.Contains(actionString, "@") {
+			if violation := usesViolation(p, actionString, guessRefType(refOf(actionString))); violation != nil {
+				return nil, violationError(file.Path, node, violation)
+			}
+			if trustedUses(p, actionString) {
+				continue
+			}
+			var githubActionRef *GithubActionRef
+			if offline {
+				githubActionRef, err = lock.actionRef(actionString)
+			} else {
+				githubActionRef, err = GetGithubActionRefWithDigest(actionString)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if violation := usesViolation(p, actionString, githubActionRef.RefType); violation != nil {
+				return nil, violationError(file.Path, node, violation)
+			}
+			if offline {
+				if rule := signatureRule(p, githubActionRef); rule != nil {
+					utils.Warnf("%s:%d: %s: signature not checked offline (%s)\n", file.Path, node.Line, actionString, rule)
 				}
-				pinnedActionString := fmt.Sprintf("docker://%s", dockerImageRef.OriginalName("digest"))
-				if pinnedActionString == actionString {
-					tmpWorkflowWriter.WriteString(fmt.Sprintf("%s\n", line))
+			} else if violation := signatureViolation(p, githubActionRef); violation != nil {
+				return nil, violationError(file.Path, node, violation)
+			}
+			pinnedActionString := githubActionRef.NameWithDigest()
+			renamedActionRef := githubActionRef
+			if FixRenamed && githubActionRef.Canonical != "" {
+				renamedActionRef = githubActionRef.canonicalRef()
+			}
+			renamedActionString := renamedActionRef.NameWithDigest()
+			if pinnedActionString == actionString {
+				comment, ok := parsePinComment(lineComment(content, node))
+				if !ok {
+					if renamedActionString != actionString {
+						edits = append(edits, yamlEdit{
+							node:  node,
+							value: renamedActionString,
+						})
+					}
 					continue
 				}
-				comment := fmt.Sprintf(" # %s", dockerImageRef.Raw)
-				tmpWorkflowWriter.WriteString(fmt.Sprintf("%s%s%s\n", matches["pre"], pinnedActionString, comment))
-			} else if ok, matches := utils.MatchNamedRegex(usesActionRegex, line); ok {
-				actionString = matches["actionString"]
-				githubActionRef, err := GetGithubActionRefWithDigest(actionString)
-				if err != nil {
-					return err
+				if !offline {
+					warnings, err := verifyPinComment(githubActionRef, comment)
+					if err != nil {
+						return nil, err
+					}
+					warnings.Print()
 				}
-				pinnedActionString := githubActionRef.NameWithDigest()
-				if pinnedActionString == actionString {
-					tmpWorkflowWriter.WriteString(fmt.Sprintf("%s\n", line))
-					continue
+				edit := yamlEdit{
+					node:  node,
+					value: renamedActionString,
 				}
-				comment := fmt.Sprintf(" # %s", githubActionRef.Raw)
-				if otherNamesArr := githubActionRef.OtherRefNames; len(otherNamesArr) > 0 {
-					otherNames := strings.Join(otherNamesArr, ",")
-					comment = fmt.Sprintf("%s | %s", comment, otherNames)
+				if migrated := formatPinComment(comment.actionRef(renamedActionRef)); MigrateComments && migrated != lineComment(content, node) {
+					edit.comment = migrated
 				}
-				tmpWorkflowWriter.WriteString(fmt.Sprintf("%s%s%s\n", matches["pre"], pinnedActionString, comment))
-			} else {
-				tmpWorkflowWriter.WriteString(fmt.Sprintf("%s\n", line))
+				if edit.value != actionString || edit.comment != "" {
+					edits = append(edits, edit)
+				}
+				continue
 			}
+			pinned = append(pinned, &PinnedAction{Uses: actionString, GithubActionRef: renamedActionRef})
+			edits = append(edits, yamlEdit{
+				node:    node,
+				value:   renamedActionString,
+				comment: formatPinComment(renamedActionRef),
+			})
+		}
+	}
+
+	imageNodes, err := file.images(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range imageNodes {
+		imageString := node.Value
+		if violation := p.CheckImage(imageString); violation != nil {
+			return nil, violationError(file.Path, node, violation)
+		}
+		dockerImageRef, err := docker.ParseImageRef(imageString)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file.Path, node.Line, err)
+		}
+		if dockerImageRef.Digest != "" {
+			continue
+		}
+		if offline {
+			dockerImageRef, err = lock.imageRef(imageString)
 		} else {
-			tmpWorkflowWriter.WriteString(fmt.Sprintf("%s\n", line))
+			dockerImageRef, err = docker.GetImageRefWithDigest(imageString)
+		}
+		if err != nil {
+			return nil, err
 		}
+		edits = append(edits, yamlEdit{
+			node:    node,
+			value:   dockerImageRef.OriginalName("digest"),
+			comment: dockerImageRef.Raw,
+		})
 	}
-	return nil
+
+	pinnedContent, err := applyYAMLEdits(content, edits)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Path, err)
+	}
+
+	err = os.WriteFile(fmt.Sprintf("%s.tmp", file.Path), pinnedContent, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return pinned, nil
+}
+
+// PinnedAction is an action PinWorkflow pinned: the uses: reference it
+// found and the action ref it was pinned to.
+type PinnedAction struct {
+	Uses            string
+	GithubActionRef *GithubActionRef
+}
+
+// String returns the substitution, along with the signature of what the
+// action was pinned to when that is known.
+func (p *PinnedAction) String() string {
+	if p.GithubActionRef.Signature == nil {
+		return fmt.Sprintf("%s -> %s", p.Uses, p.GithubActionRef.NameWithDigest())
+	}
+	return fmt.Sprintf("%s -> %s (%s)", p.Uses, p.GithubActionRef.NameWithDigest(), p.GithubActionRef.Signature)
+}
+
+// pinDockerfile pins the base images of the Dockerfile of a docker container
+// action the way pinny docker pin does, and moves the result to <file>.tmp.
+// When offline is set, digests are taken from the docker lock file.
+func pinDockerfile(file *UsesFile, offline bool) error {
+	pinnedFile := fmt.Sprintf("%s.pinned.tmp", file.Path)
+	err := docker.GeneratePinnedDockerfile(file.Path, offline)
+	if err != nil {
+		os.Remove(pinnedFile)
+		return err
+	}
+	return os.Rename(pinnedFile, fmt.Sprintf("%s.tmp", file.Path))
+}
+
+func refContainsKey(owner, repo, base, target string) string {
+	return fmt.Sprintf("%s/%s/%s...%s", owner, repo, base, target)
 }
 
 func refContains(ctx context.Context, c *github.Client, owner, repo, base, target string) (bool, error) {
-	diff, resp, err := c.Repositories.CompareCommits(ctx, owner, repo, base, target, &github.ListOptions{PerPage: 1})
+	return refContainsCache.Do(refContainsKey(owner, repo, base, target), func() (bool, error) {
+		return compareRefs(ctx, c, owner, repo, base, target)
+	})
+}
+
+func compareRefs(ctx context.Context, c *github.Client, owner, repo, base, target string) (bool, error) {
+	diff, resp, err := rateLimited(ctx, func() (*github.CommitsComparison, *github.Response, error) {
+		return c.Repositories.CompareCommits(ctx, owner, repo, base, target, &github.ListOptions{PerPage: 1})
+	})
 	if err != nil {
-		if resp.StatusCode == http.StatusNotFound {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
 			// NotFound can be returned for some divergent cases: "404 No common ancestor between ..."
 			return false, nil
 		}
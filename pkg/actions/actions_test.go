@@ -0,0 +1,137 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+const (
+	standInTagSHA    = "1111111111111111111111111111111111111111"
+	standInCommitSHA = "2222222222222222222222222222222222222222"
+	standInMainSHA   = "3333333333333333333333333333333333333333"
+	standInForkSHA   = "4444444444444444444444444444444444444444"
+)
+
+// standInAPI serves the refs, repo and comparisons of the stand-in/act repo
+// the way the Github API does, and records the paths requested.
+type standInAPI struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (s *standInAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.paths = append(s.paths, r.URL.Path)
+	s.mu.Unlock()
+
+	refs := []map[string]any{
+		{"ref": "refs/heads/main", "object": map[string]string{"sha": standInMainSHA, "type": "commit"}},
+		{"ref": "refs/tags/v1", "object": map[string]string{"sha": standInTagSHA, "type": "commit"}},
+		{"ref": "refs/tags/v1.0.0", "object": map[string]string{"sha": standInTagSHA, "type": "commit"}},
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/repos/stand-in/act")
+	var body any
+	switch {
+	case strings.HasPrefix(path, "/git/matching-refs/"):
+		prefix := "refs/" + strings.TrimPrefix(path, "/git/matching-refs/")
+		matching := []map[string]any{}
+		for _, ref := range refs {
+			if strings.HasPrefix(ref["ref"].(string), prefix) {
+				matching = append(matching, ref)
+			}
+		}
+		body = matching
+	case path == "/git/refs" || path == "/git/matching-refs":
+		body = refs
+	case path == "":
+		body = map[string]string{"full_name": "stand-in/act", "default_branch": "main"}
+	case path == "/compare/refs/heads/main..."+standInCommitSHA:
+		body = map[string]string{"status": "behind"}
+	case strings.HasPrefix(path, "/compare/"):
+		body = map[string]string{"status": "diverged"}
+	case path == "/git/commits/"+standInForkSHA:
+		// commits pushed to a fork are served through the upstream repo
+		body = map[string]string{"sha": standInForkSHA}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		body = map[string]string{"message": "Not Found"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// requested returns how many of the requested paths contain fragment.
+func (s *standInAPI) requested(fragment string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, path := range s.paths {
+		if strings.Contains(path, fragment) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestResolveGithubActionRef(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	t.Setenv("NETRC", t.TempDir()+"/netrc")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "")
+	t.Setenv("GITHUB_ENTERPRISE_TOKEN", "")
+
+	api := &standInAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	err := ConfigureGithubHosts("", map[string]string{"stand-in": server.URL})
+	if err != nil {
+		t.Fatalf("ConfigureGithubHosts() error = %v", err)
+	}
+	t.Cleanup(func() {
+		delete(ownerAPIURLs, "stand-in")
+	})
+
+	tests := []struct {
+		ref          string
+		wantErr      string
+		digest       string
+		refType      string
+		reachability string
+	}{
+		{ref: "v1", digest: standInTagSHA, refType: "tag", reachability: ReachableUpstream},
+		{ref: "main", digest: standInMainSHA, refType: "branch", reachability: ReachableUpstream},
+		{ref: standInCommitSHA, digest: standInCommitSHA, refType: "commit", reachability: ReachableUpstream},
+		{ref: standInForkSHA, digest: standInForkSHA, refType: "commit", reachability: ReachableForkNetwork},
+		{ref: "v99", wantErr: "stand-in/act@v99 not found"},
+		{ref: "abcdef1", wantErr: "stand-in/act@abcdef1 not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			githubActionRef := &GithubActionRef{Owner: "stand-in", Repo: "act", Ref: tt.ref}
+			compares := api.requested("/compare/")
+			err := resolveGithubActionRef(githubActionRef, &utils.Warnings{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveGithubActionRef() error = %v, want %q", err, tt.wantErr)
+				}
+				// refs that don't exist are not worth an impostor check
+				if api.requested("/compare/") != compares {
+					t.Errorf("resolveGithubActionRef() compared %s against refs", tt.ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveGithubActionRef() error = %v", err)
+			}
+			if githubActionRef.Digest != tt.digest || githubActionRef.RefType != tt.refType || githubActionRef.Reachability != tt.reachability {
+				t.Errorf("resolveGithubActionRef() = %s %s %s, want %s %s %s", githubActionRef.Digest, githubActionRef.RefType, githubActionRef.Reachability, tt.digest, tt.refType, tt.reachability)
+			}
+		})
+	}
+}
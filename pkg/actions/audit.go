@@ -0,0 +1,518 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/advisory"
+	"github.com/koalalab-inc/pinny/pkg/findings"
+	"github.com/koalalab-inc/pinny/pkg/policy"
+
+	"github.com/google/go-github/v56/github"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StatusPinned   = "pinned"
+	StatusUnpinned = "unpinned"
+	StatusBranch   = "branch"
+	StatusLocal    = "local"
+)
+
+var fullSHARegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+var reusableWorkflowRegex = regexp.MustCompile(`^\.github/workflows/[^/]+\.ya?ml$`)
+
+// ActionDependency is a single uses: reference together with everything it
+// pulls in when transitive resolution is enabled.
+type ActionDependency struct {
+	Uses         string `json:"uses"`
+	File         string `json:"file"`
+	Line         int    `json:"line,omitempty"`
+	Status       string `json:"status"`
+	Digest       string `json:"digest,omitempty"`
+	Reachability string `json:"reachability,omitempty"`
+	Manifest     string `json:"manifest,omitempty"`
+	Error        string `json:"error,omitempty"`
+	// Trusted is set for unpinned actions the policy lets stay on a tag
+	Trusted bool `json:"trusted,omitempty"`
+	// Advisories are the advisories of the advisory database affecting the
+	// resolved commit or the release it tracks
+	Advisories []*advisory.Match `json:"advisories,omitempty"`
+	// Deprecations are the reasons the action should no longer be used,
+	// like an archived repo
+	Deprecations []*Deprecation `json:"deprecations,omitempty"`
+	// Repojacking is set when the owner/repo of the action can be
+	// registered by someone else, Canonical is the name the repo has moved
+	// to if it was renamed or transferred
+	Repojacking  string              `json:"repojacking,omitempty"`
+	Canonical    string              `json:"canonical,omitempty"`
+	Signature    *Signature          `json:"signature,omitempty"`
+	Findings     []*findings.Finding `json:"findings,omitempty"`
+	Dependencies []*ActionDependency `json:"dependencies,omitempty"`
+}
+
+// Mutable reports whether the dependency can change without the referencing
+// file changing.
+func (d *ActionDependency) Mutable() bool {
+	return d.Status == StatusUnpinned || d.Status == StatusBranch
+}
+
+// Violations returns the findings of the dependency that break the policy.
+func (d *ActionDependency) Violations() []*findings.Finding {
+	violations := []*findings.Finding{}
+	for _, finding := range d.Findings {
+		if finding.Rule != "" {
+			violations = append(violations, finding)
+		}
+	}
+	return violations
+}
+
+// Impostor reports whether the dependency is pinned to a commit that no
+// branch or tag of the upstream repo contains.
+func (d *ActionDependency) Impostor() bool {
+	return d.Reachability == ReachableForkNetwork || d.Reachability == ReachableMissing
+}
+
+// Walk calls fn for d and every dependency below it.
+func (d *ActionDependency) Walk(fn func(*ActionDependency)) {
+	fn(d)
+	for _, child := range d.Dependencies {
+		child.Walk(fn)
+	}
+}
+
+func (d *ActionDependency) addFinding(message string) {
+	d.Findings = append(d.Findings, &findings.Finding{
+		File:    d.File,
+		Line:    d.Line,
+		Ref:     d.Uses,
+		Message: message,
+	})
+}
+
+func (d *ActionDependency) addAdvisory(match *advisory.Match) {
+	d.Advisories = append(d.Advisories, match)
+	d.addFinding(match.String())
+}
+
+func (d *ActionDependency) addDeprecation(deprecation *Deprecation) {
+	d.Deprecations = append(d.Deprecations, deprecation)
+	d.addFinding(deprecation.String())
+}
+
+func (d *ActionDependency) addRepojacking(message string) {
+	d.Repojacking = message
+	d.addFinding(message)
+}
+
+func (d *ActionDependency) addViolation(violation *policy.Violation) {
+	d.Findings = append(d.Findings, &findings.Finding{
+		File:    d.File,
+		Line:    d.Line,
+		Ref:     d.Uses,
+		Message: violation.Message,
+		Rule:    violation.Rule.String(),
+	})
+}
+
+type WorkflowAudit struct {
+	File         string              `json:"file"`
+	Dependencies []*ActionDependency `json:"dependencies"`
+}
+
+type AuditReport struct {
+	Workflows    []*WorkflowAudit `json:"workflows"`
+	Mutable      int              `json:"mutable"`
+	Impostors    int              `json:"impostors"`
+	Inconclusive int              `json:"inconclusive"`
+	Advisories   int              `json:"advisories"`
+	Deprecated   int              `json:"deprecated"`
+	Repojackable int              `json:"repojackable"`
+	Violations   int              `json:"violations"`
+	// Errors counts the references that could not be checked because a
+	// lookup failed
+	Errors int `json:"errors"`
+}
+
+// Walk calls fn for every dependency in the report.
+func (r *AuditReport) Walk(fn func(*ActionDependency)) {
+	for _, audit := range r.Workflows {
+		for _, dep := range audit.Dependencies {
+			dep.Walk(fn)
+		}
+	}
+}
+
+type auditor struct {
+	ctx        context.Context
+	transitive bool
+	policy     *policy.Policy
+	advisories *advisory.Database
+	// manifests already walked, keyed by owner/repo/path@sha
+	visited map[string][]*ActionDependency
+	// manifests currently being walked, used to break cycles
+	walking map[string]bool
+	// action manifests fetched at the resolved SHA, keyed by
+	// owner/repo/path@sha
+	manifests map[string]*fetchedManifest
+}
+
+type fetchedManifest struct {
+	content []byte
+	path    string
+	err     error
+}
+
+func isFullSHA(ref string) bool {
+	return fullSHARegex.MatchString(ref)
+}
+
+func isReusableWorkflow(g *GithubActionRef) bool {
+	return reusableWorkflowRegex.MatchString(g.Path)
+}
+
+// AuditWorkflows reports the status of every uses: reference in the
+// workflows and action manifests. With transitive set, the manifests of
+// composite actions and reusable workflows are fetched at the resolved SHA
+// and walked as well. Every resolved action is matched against advisories
+// and checked for an archived or disabled repo and a retired runtime.
+func AuditWorkflows(transitive bool, advisories *advisory.Database) (*AuditReport, error) {
+	files, err := FindUsesFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, NoUsesFilesError()
+	}
+
+	p, err := policy.Load()
+	if err != nil {
+		return nil, err
+	}
+	// the report shows the signature of every action
+	ResolveSignatures = true
+	prefetchWorkflows(false, false)
+
+	a := &auditor{
+		ctx:        context.Background(),
+		transitive: transitive,
+		policy:     p,
+		advisories: advisories,
+		visited:    make(map[string][]*ActionDependency),
+		walking:    make(map[string]bool),
+		manifests:  make(map[string]*fetchedManifest),
+	}
+
+	audits := []*WorkflowAudit{}
+	for _, file := range files {
+		content, usesNodes, err := file.read()
+		if err != nil {
+			return nil, err
+		}
+		imageNodes, err := file.images(content)
+		if err != nil {
+			return nil, err
+		}
+		audits = append(audits, &WorkflowAudit{
+			File:         file.Path,
+			Dependencies: append(a.auditNodes(file.Path, content, usesNodes), a.auditImages(file.Path, imageNodes)...),
+		})
+	}
+	report := &AuditReport{
+		Workflows: audits,
+	}
+	report.Walk(func(dep *ActionDependency) {
+		if dep.Mutable() && !dep.Trusted {
+			report.Mutable++
+		}
+		if dep.Impostor() {
+			report.Impostors++
+		}
+		if dep.Reachability == ReachableInconclusive {
+			report.Inconclusive++
+		}
+		report.Advisories += len(dep.Advisories)
+		if len(dep.Deprecations) > 0 {
+			report.Deprecated++
+		}
+		if dep.Repojacking != "" {
+			report.Repojackable++
+		}
+		report.Violations += len(dep.Violations())
+		if dep.Error != "" {
+			report.Errors++
+		}
+	})
+	return report, nil
+}
+
+// auditNodes audits the uses: references found in file. file is a path in
+// the local repo or owner/repo/path@sha for manifests fetched from Github.
+func (a *auditor) auditNodes(file string, content []byte, nodes []*yaml.Node) []*ActionDependency {
+	deps := []*ActionDependency{}
+	for _, node := range nodes {
+		deps = append(deps, a.auditUses(file, node.Line, node.Value, lineComment(content, node)))
+	}
+	return deps
+}
+
+// auditImages audits the job container and service images found in file.
+func (a *auditor) auditImages(file string, nodes []*yaml.Node) []*ActionDependency {
+	deps := []*ActionDependency{}
+	for _, node := range nodes {
+		deps = append(deps, a.auditImage(file, node.Line, node.Value))
+	}
+	return deps
+}
+
+// auditImage audits a docker:// reference or a job container or service
+// image against the image rules of the policy.
+func (a *auditor) auditImage(file string, line int, image string) *ActionDependency {
+	dep := &ActionDependency{
+		Uses:   image,
+		File:   file,
+		Line:   line,
+		Status: StatusUnpinned,
+	}
+	if strings.Contains(image, "@sha256:") {
+		dep.Status = StatusPinned
+	}
+	if violation := imageViolation(a.policy, image); violation != nil {
+		dep.addViolation(violation)
+	}
+	return dep
+}
+
+// auditUses audits a single uses: reference. comment is the trailing
+// comment of the reference, which names the release a pinned SHA tracks.
+func (a *auditor) auditUses(file string, line int, uses string, comment string) *ActionDependency {
+	dep := &ActionDependency{
+		Uses: uses,
+		File: file,
+		Line: line,
+	}
+
+	if strings.HasPrefix(uses, "./") || strings.HasPrefix(uses, "../") {
+		dep.Status = StatusLocal
+		return dep
+	}
+
+	if strings.HasPrefix(uses, "docker://") {
+		return a.auditImage(file, line, uses)
+	}
+
+	if violation := usesViolation(a.policy, uses, ""); violation != nil {
+		dep.addViolation(violation)
+	}
+	dep.Trusted = trustedUses(a.policy, uses)
+
+	githubActionRef, err := GetGithubActionRefWithDigest(uses)
+	if err != nil {
+		dep.Status = StatusUnpinned
+		if isFullSHA(refOf(uses)) {
+			dep.Status = StatusPinned
+		}
+		var missingOwner *MissingOwnerError
+		if errors.As(err, &missingOwner) {
+			dep.addRepojacking(err.Error())
+		} else {
+			dep.Error = err.Error()
+		}
+		// advisories need no network, so compromised commits are reported
+		// even where Github is out of reach, like in air-gapped CI
+		if parsedActionRef, err := parseActionString(uses); err == nil {
+			if isFullSHA(parsedActionRef.Ref) {
+				parsedActionRef.Digest = parsedActionRef.Ref
+			}
+			a.matchAdvisories(dep, parsedActionRef, comment)
+		}
+		return dep
+	}
+	if githubActionRef.Canonical != "" {
+		dep.Canonical = githubActionRef.Canonical
+		dep.addRepojacking(fmt.Sprintf("%s, use %s", renamedWarning(githubActionRef), githubActionRef.canonicalRef().NameWithRef()))
+	}
+
+	dep.Digest = githubActionRef.Digest
+	dep.Signature = githubActionRef.Signature
+	if violation := signatureViolation(a.policy, githubActionRef); violation != nil {
+		dep.addViolation(violation)
+	}
+	switch {
+	case isFullSHA(githubActionRef.Ref):
+		dep.Status = StatusPinned
+		dep.Reachability = githubActionRef.Reachability
+		switch dep.Reachability {
+		case ReachableForkNetwork:
+			dep.addFinding("impostor commit, not reachable from any branch or tag of the upstream repo")
+		case ReachableMissing:
+			dep.addFinding("commit does not exist in the upstream repo or its forks")
+		case ReachableInconclusive:
+			dep.addFinding("no branch or tag containing the commit was found within the comparison budget, it may be an impostor commit")
+		}
+	case githubActionRef.RefType == "branch":
+		dep.Status = StatusBranch
+		// only branches are left to check now the ref type is known
+		if len(dep.Violations()) == 0 {
+			if violation := usesViolation(a.policy, uses, githubActionRef.RefType); violation != nil {
+				dep.addViolation(violation)
+			}
+		}
+	default:
+		dep.Status = StatusUnpinned
+	}
+	a.matchAdvisories(dep, githubActionRef, comment)
+	a.checkDeprecations(dep, githubActionRef)
+
+	if a.transitive {
+		a.walk(dep, githubActionRef)
+	}
+	return dep
+}
+
+// matchAdvisories adds the advisories affecting the commit of dep. The
+// release it tracks is the most specific version tag of the ref, the tags
+// pointing at the same commit and the pin comment. githubActionRef may be
+// unresolved, its Digest is then only set if it is pinned to a full SHA.
+func (a *auditor) matchAdvisories(dep *ActionDependency, githubActionRef *GithubActionRef, comment string) {
+	if a.advisories == nil {
+		return
+	}
+	names := append([]string{githubActionRef.Ref}, githubActionRef.OtherRefNames...)
+	if parsed, ok := parsePinComment(comment); ok {
+		names = append(names, parsed.Ref)
+		names = append(names, parsed.OtherRefNames...)
+	}
+	version := ""
+	if best, ok := mostSpecificVersion(names); ok {
+		version = best.Raw
+	}
+	action := fmt.Sprintf("%s/%s", githubActionRef.Owner, githubActionRef.Repo)
+	for _, match := range a.advisories.Match(action, githubActionRef.Digest, version) {
+		dep.addAdvisory(match)
+	}
+}
+
+func refOf(uses string) string {
+	if i := strings.LastIndex(uses, "@"); i >= 0 {
+		return uses[i+1:]
+	}
+	return ""
+}
+
+// walk fetches the manifest behind githubActionRef at its resolved SHA and
+// audits the references it contains.
+func (a *auditor) walk(dep *ActionDependency, githubActionRef *GithubActionRef) {
+	key := githubActionRef.NameWithDigest()
+	if deps, ok := a.visited[key]; ok {
+		dep.Dependencies = deps
+		return
+	}
+	if a.walking[key] {
+		dep.Error = "dependency cycle"
+		return
+	}
+	a.walking[key] = true
+	defer delete(a.walking, key)
+
+	var content []byte
+	var usesNodes, imageNodes []*yaml.Node
+	if isReusableWorkflow(githubActionRef) {
+		var err error
+		content, err = a.getFileContents(githubActionRef, githubActionRef.Digest, githubActionRef.Path)
+		if err != nil {
+			dep.Error = err.Error()
+			return
+		}
+		dep.Manifest = githubActionRef.Path
+		usesNodes, err = findWorkflowUsesNodes(content)
+		if err != nil {
+			dep.Error = err.Error()
+			return
+		}
+		imageNodes, err = findWorkflowImageNodes(content)
+		if err != nil {
+			dep.Error = err.Error()
+			return
+		}
+	} else {
+		var manifest string
+		var err error
+		content, manifest, err = a.actionManifest(githubActionRef)
+		if err != nil {
+			dep.Error = err.Error()
+			return
+		}
+		dep.Manifest = manifest
+		usesNodes, err = findActionUsesNodes(content)
+		if err != nil {
+			dep.Error = err.Error()
+			return
+		}
+	}
+
+	manifestFile := fmt.Sprintf("%s/%s/%s@%s", githubActionRef.Owner, githubActionRef.Repo, dep.Manifest, githubActionRef.Digest)
+	dep.Dependencies = append(a.auditNodes(manifestFile, content, usesNodes), a.auditImages(manifestFile, imageNodes)...)
+	a.visited[key] = dep.Dependencies
+}
+
+// actionManifest returns the manifest of an action at its resolved SHA and
+// its path in the repo. Manifests are fetched once per audit.
+func (a *auditor) actionManifest(githubActionRef *GithubActionRef) ([]byte, string, error) {
+	key := githubActionRef.NameWithDigest()
+	if fetched, ok := a.manifests[key]; ok {
+		return fetched.content, fetched.path, fetched.err
+	}
+	content, manifest, err := a.getActionManifest(githubActionRef, githubActionRef.Digest)
+	a.manifests[key] = &fetchedManifest{
+		content: content,
+		path:    manifest,
+		err:     err,
+	}
+	return content, manifest, err
+}
+
+// getActionManifest returns the manifest of an action at ref and its path in
+// the repo.
+func (a *auditor) getActionManifest(githubActionRef *GithubActionRef, ref string) ([]byte, string, error) {
+	var lastErr error
+	for _, name := range []string{"action.yml", "action.yaml"} {
+		manifest := path.Join(githubActionRef.Path, name)
+		content, err := a.getFileContents(githubActionRef, ref, manifest)
+		if err == nil {
+			return content, manifest, nil
+		}
+		lastErr = err
+	}
+	return nil, "", fmt.Errorf("no action manifest found for %s@%s: %w", path.Join(githubActionRef.Owner, githubActionRef.Repo, githubActionRef.Path), ref, lastErr)
+}
+
+func (a *auditor) getFileContents(githubActionRef *GithubActionRef, ref string, filePath string) ([]byte, error) {
+	opts := &github.RepositoryContentGetOptions{
+		Ref: ref,
+	}
+	client, err := getGithubClient(githubActionRef.Owner)
+	if err != nil {
+		return nil, err
+	}
+	file, _, err := rateLimited(a.ctx, func() (*github.RepositoryContent, *github.Response, error) {
+		file, _, resp, err := client.Repositories.GetContents(a.ctx, githubActionRef.Owner, githubActionRef.Repo, filePath, opts)
+		return file, resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s is a directory", filePath)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
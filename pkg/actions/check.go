@@ -0,0 +1,124 @@
+package actions
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/findings"
+	"github.com/koalalab-inc/pinny/pkg/policy"
+	"gopkg.in/yaml.v3"
+)
+
+var shortSHARegex = regexp.MustCompile(`^[0-9a-f]{4,39}$`)
+
+// Refs that are almost always branches. Anything else that is not a SHA is
+// reported as a mutable ref since tags and branches can't be told apart
+// without asking Github.
+var wellKnownBranches = map[string]bool{
+	"main":    true,
+	"master":  true,
+	"develop": true,
+	"dev":     true,
+	"trunk":   true,
+}
+
+// CheckWorkflows reports every uses: reference and job container and service
+// image in the workflows and action manifests that is not pinned to a full
+// commit SHA or image digest, or breaks the policy. It works offline.
+func CheckWorkflows() ([]*findings.Finding, error) {
+	p, err := policy.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := FindUsesFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*findings.Finding{}
+	for _, file := range files {
+		content, usesNodes, err := file.read()
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range usesNodes {
+			if finding := checkUses(p, file.Path, node); finding != nil {
+				results = append(results, finding)
+			}
+		}
+		imageNodes, err := file.images(content)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range imageNodes {
+			if finding := checkImage(p, file.Path, node); finding != nil {
+				results = append(results, finding)
+			}
+		}
+	}
+	return results, nil
+}
+
+func checkImage(p *policy.Policy, file string, node *yaml.Node) *findings.Finding {
+	image := node.Value
+	if violation := p.CheckImage(image); violation != nil {
+		return violationFinding(file, node, violation)
+	}
+	if strings.Contains(image, "@sha256:") {
+		return nil
+	}
+	finding := &findings.Finding{
+		File:    file,
+		Line:    node.Line,
+		Ref:     image,
+		Message: "container image is not pinned to a digest",
+	}
+	if rule := p.DigestRule(image); rule != nil {
+		finding.Rule = rule.String()
+	}
+	return finding
+}
+
+func checkUses(p *policy.Policy, file string, node *yaml.Node) *findings.Finding {
+	uses := node.Value
+	finding := &findings.Finding{
+		File: file,
+		Line: node.Line,
+		Ref:  uses,
+	}
+
+	if strings.HasPrefix(uses, "./") || strings.HasPrefix(uses, "../") {
+		return nil
+	}
+
+	if violation := usesViolation(p, uses, guessRefType(refOf(uses))); violation != nil {
+		return violationFinding(file, node, violation)
+	}
+
+	if strings.HasPrefix(uses, "docker://") {
+		if strings.Contains(uses, "@sha256:") {
+			return nil
+		}
+		finding.Message = "docker image is not pinned to a digest"
+		if rule := p.DigestRule(uses); rule != nil {
+			finding.Rule = rule.String()
+		}
+		return finding
+	}
+
+	ref := refOf(uses)
+	switch {
+	case isFullSHA(ref) || trustedUses(p, uses):
+		return nil
+	case ref == "":
+		finding.Message = "action has no ref"
+	case shortSHARegex.MatchString(ref):
+		finding.Message = "short SHA, use the full 40 character commit SHA"
+	case wellKnownBranches[ref]:
+		finding.Message = "branch ref, pin to a full commit SHA"
+	default:
+		finding.Message = "mutable ref, pin to a full commit SHA"
+	}
+	return finding
+}
@@ -0,0 +1,216 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/koalalab-inc/pinny/pkg/auth"
+
+	"github.com/google/go-github/v56/github"
+)
+
+const defaultGithubAPIURL = "https://api.github.com/"
+
+// githubAPIURL is the API every action is resolved against unless its owner
+// is routed to another host through ownerAPIURLs.
+var githubAPIURL = defaultGithubAPIURL
+
+// ownerAPIURLs routes the actions of an owner to a specific API, e.g. the
+// actions of an org living on a Github Enterprise Server instance while
+// public actions are fetched from github.com through Github Connect.
+var ownerAPIURLs = make(map[string]string)
+
+// githubClientsMu guards githubClients and githubCredentials. It is held
+// while a credential is looked up, so concurrent lookups for a host do not
+// each exchange a Github App token.
+var githubClientsMu sync.Mutex
+
+var githubClients = make(map[string]*github.Client)
+
+var githubCredentials = make(map[string]*auth.Credential)
+
+func normalizeAPIURL(apiURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(apiURL))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("invalid Github API URL %q, expected e.g. https://github.example.com/api/v3", apiURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String(), nil
+}
+
+// ConfigureGithubHosts sets the API actions are resolved against. apiURL is
+// used for every owner not listed in ownerURLs, an empty apiURL keeps
+// github.com. For Github Enterprise Server use https://<host>/api/v3.
+func ConfigureGithubHosts(apiURL string, ownerURLs map[string]string) error {
+	if apiURL != "" {
+		normalized, err := normalizeAPIURL(apiURL)
+		if err != nil {
+			return err
+		}
+		githubAPIURL = normalized
+	}
+	for owner, ownerURL := range ownerURLs {
+		normalized, err := normalizeAPIURL(ownerURL)
+		if err != nil {
+			return err
+		}
+		ownerAPIURLs[strings.ToLower(owner)] = normalized
+	}
+	return nil
+}
+
+func apiURLForOwner(owner string) string {
+	if apiURL, ok := ownerAPIURLs[strings.ToLower(owner)]; ok {
+		return apiURL
+	}
+	return githubAPIURL
+}
+
+func isGithubDotCom(apiURL string) bool {
+	return apiURL == defaultGithubAPIURL
+}
+
+// tokenEnvVars returns the environment variables holding the token for
+// apiURL. The default host uses GITHUB_TOKEN or GH_TOKEN, and on Github
+// Enterprise Server GH_ENTERPRISE_TOKEN or GITHUB_ENTERPRISE_TOKEN first,
+// as Actions runners on Github Enterprise Server provide their token in
+// GITHUB_TOKEN. Hosts owners are routed to never get the token of the
+// default host: github.com uses GITHUB_COM_TOKEN, and another Github
+// Enterprise Server host GH_ENTERPRISE_TOKEN only when the default host is
+// github.com.
+func tokenEnvVars(apiURL string) []string {
+	if apiURL == githubAPIURL {
+		if isGithubDotCom(apiURL) {
+			return []string{"GITHUB_TOKEN", "GH_TOKEN"}
+		}
+		return []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN", "GITHUB_TOKEN", "GH_TOKEN"}
+	}
+	if isGithubDotCom(apiURL) {
+		return []string{"GITHUB_COM_TOKEN"}
+	}
+	if isGithubDotCom(githubAPIURL) {
+		return []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+	}
+	return []string{}
+}
+
+// getGithubClient returns the client for the host the actions of owner live
+// on.
+func getGithubClient(owner string) (*github.Client, error) {
+	apiURL := apiURLForOwner(owner)
+	githubClientsMu.Lock()
+	defer githubClientsMu.Unlock()
+	if client, ok := githubClients[apiURL]; ok {
+		return client, nil
+	}
+
+	credential, err := auth.Lookup(apiURL, tokenEnvVars(apiURL), apiURL == githubAPIURL)
+	if err != nil {
+		return nil, err
+	}
+	// the token is set below the rate limit transport, so requests retried
+	// after a long wait go out with a renewed token
+	client := github.NewClient(&http.Client{
+		Transport: &cacheTransport{
+			base: &rateLimitTransport{
+				base: &tokenTransport{base: http.DefaultTransport, credential: credential},
+			},
+			credential: credentialID(credential),
+		},
+	})
+	if !isGithubDotCom(apiURL) {
+		// apiURL was validated by ConfigureGithubHosts
+		baseURL, _ := url.Parse(apiURL)
+		client.BaseURL = baseURL
+	}
+	githubClients[apiURL] = client
+	githubCredentials[apiURL] = credential
+	return client, nil
+}
+
+// tokenTransport authenticates requests with the current token of
+// credential, or sends them anonymously if credential is nil.
+type tokenTransport struct {
+	base       http.RoundTripper
+	credential *auth.Credential
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.credential == nil {
+		return t.base.RoundTrip(req)
+	}
+	token, err := t.credential.CurrentToken()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	return t.base.RoundTrip(req)
+}
+
+// credentialID identifies credential in cache keys, as a token may see repos
+// another one does not.
+func credentialID(credential *auth.Credential) string {
+	if credential == nil {
+		return "anonymous"
+	}
+	return credential.ID()
+}
+
+// HostStatus describes the credential used for a Github host.
+type HostStatus struct {
+	Host      string
+	APIURL    string
+	Source    string
+	Scopes    string
+	RateLimit *github.Rate
+}
+
+// AuthStatus reports the credential source, token scopes and remaining rate
+// limit of every configured Github host.
+func AuthStatus() ([]*HostStatus, error) {
+	apiURLs := []string{githubAPIURL}
+	owners := []string{""}
+	for owner, apiURL := range ownerAPIURLs {
+		if !slices.Contains(apiURLs, apiURL) {
+			apiURLs = append(apiURLs, apiURL)
+			owners = append(owners, owner)
+		}
+	}
+
+	statuses := []*HostStatus{}
+	for i, apiURL := range apiURLs {
+		client, err := getGithubClient(owners[i])
+		if err != nil {
+			return nil, err
+		}
+		status := &HostStatus{
+			Host:   auth.Hostname(apiURL),
+			APIURL: apiURL,
+			Source: "none, requests are anonymous",
+		}
+		githubClientsMu.Lock()
+		if credential := githubCredentials[apiURL]; credential != nil {
+			status.Source = credential.Source
+		}
+		githubClientsMu.Unlock()
+		limits, resp, err := client.RateLimits(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", status.Host, err)
+		}
+		status.Scopes = resp.Header.Get("X-OAuth-Scopes")
+		status.RateLimit = limits.GetCore()
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
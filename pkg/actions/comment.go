@@ -0,0 +1,264 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+var pinCommentRegex = regexp.MustCompile(`^(?P<action>[^/\s]+/[^@\s]+@(?P<ref>[^\s|]+))(\s*\|\s*(?P<others>\S+))?$`)
+
+// versionCommentRegex matches the `v3.5.3` and `tag=v3.5.3` comments
+// Dependabot and Renovate write and understand.
+var versionCommentRegex = regexp.MustCompile(`^(tag=)?(?P<ref>\S+)$`)
+
+// refCommentRegex matches the default `v3.5.3 | v3` comments, the version
+// followed by the ref that was pinned.
+var refCommentRegex = regexp.MustCompile(`^(?P<version>[^\s|]+)\s*\|\s*(?P<ref>[^\s|]+)$`)
+
+const (
+	// DefaultCommentTemplate writes the version Dependabot and Renovate
+	// understand followed by the ref that was pinned, e.g. `v3.5.3 | v3`, so
+	// update and unpin keep tracking v3
+	DefaultCommentTemplate = "{version} | {ref}"
+	// LegacyCommentTemplate names the format older pinny versions wrote,
+	// e.g. `actions/checkout@v3 | v3.5.3`
+	LegacyCommentTemplate = "legacy"
+)
+
+// CommentTemplate is the trailing comment written next to pinned actions,
+// set with --comment-template. {version} is replaced by the most specific
+// semver tag of the pinned commit, {ref} by the ref that was pinned, {action}
+// by the action with that ref and {refs} by the other tags and branches
+// pointing to the commit. Commits without a semver tag are commented with
+// the action, unless the template names {action}.
+var CommentTemplate = DefaultCommentTemplate
+
+// MigrateComments rewrites the comments of pinned actions that don't match
+// CommentTemplate, set with --migrate-comments.
+var MigrateComments = false
+
+// pinComment is the trailing comment pinny writes next to a pinned action,
+// e.g. `v3.5.3 | v3` or `actions/checkout@v3 | v3.5.3`. Action is empty if
+// the comment doesn't name the action.
+type pinComment struct {
+	Action        string
+	Ref           string
+	OtherRefNames []string
+}
+
+func parsePinComment(comment string) (*pinComment, bool) {
+	comment = strings.TrimSpace(comment)
+	if ok, matches := utils.MatchNamedRegex(pinCommentRegex, comment); ok {
+		parsed := &pinComment{
+			Action:        matches["action"],
+			Ref:           matches["ref"],
+			OtherRefNames: []string{},
+		}
+		if matches["others"] != "" {
+			parsed.OtherRefNames = strings.Split(matches["others"], ",")
+		}
+		return parsed, true
+	}
+	if ok, matches := utils.MatchNamedRegex(refCommentRegex, comment); ok {
+		if _, ok := utils.ParseSemver(matches["version"]); ok {
+			return &pinComment{
+				Ref:           matches["ref"],
+				OtherRefNames: []string{matches["version"]},
+			}, true
+		}
+	}
+	// a lone word is only taken for a ref if it is a version, so other
+	// comments are left alone
+	if ok, matches := utils.MatchNamedRegex(versionCommentRegex, comment); ok {
+		if _, ok := utils.ParseSemver(matches["ref"]); ok {
+			return &pinComment{
+				Ref:           matches["ref"],
+				OtherRefNames: []string{},
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// ValidateCommentTemplate checks that template is the legacy format or
+// names at least one placeholder.
+func ValidateCommentTemplate(template string) error {
+	if template == LegacyCommentTemplate {
+		return nil
+	}
+	for _, placeholder := range []string{"{version}", "{ref}", "{action}", "{refs}"} {
+		if strings.Contains(template, placeholder) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid comment template %q, use %s or a template with {version}, {ref}, {action} or {refs}", template, LegacyCommentTemplate)
+}
+
+func formatPinComment(githubActionRef *GithubActionRef) string {
+	if CommentTemplate == LegacyCommentTemplate {
+		comment := githubActionRef.Raw
+		if otherNamesArr := githubActionRef.OtherRefNames; len(otherNamesArr) > 0 {
+			otherNames := strings.Join(otherNamesArr, ",")
+			comment = fmt.Sprintf("%s | %s", comment, otherNames)
+		}
+		return comment
+	}
+
+	best, ok := mostSpecificVersion(append([]string{githubActionRef.Ref}, githubActionRef.OtherRefNames...))
+	if !ok && !strings.Contains(CommentTemplate, "{action}") {
+		// a lone word is only read back if it is a version, so branches and
+		// other tags are written with the action, like owner/repo@main
+		return githubActionRef.Raw
+	}
+	version := githubActionRef.Ref
+	if ok {
+		version = best.Raw
+	}
+	if CommentTemplate == DefaultCommentTemplate && version == githubActionRef.Ref {
+		// v3.5.3 | v3.5.3 says no more than v3.5.3
+		return version
+	}
+	replacer := strings.NewReplacer(
+		"{version}", version,
+		"{ref}", githubActionRef.Ref,
+		"{action}", githubActionRef.Raw,
+		"{refs}", strings.Join(githubActionRef.OtherRefNames, ","),
+	)
+	return strings.TrimSpace(replacer.Replace(CommentTemplate))
+}
+
+// actionRef returns the ref the comment was written for, with the refs the
+// comment names.
+func (c *pinComment) actionRef(pinned *GithubActionRef) *GithubActionRef {
+	raw := c.Action
+	if raw == "" {
+		name := strings.TrimSuffix(pinned.Raw, fmt.Sprintf("@%s", pinned.Ref))
+		raw = fmt.Sprintf("%s@%s", name, c.Ref)
+	}
+	return &GithubActionRef{
+		Raw:           raw,
+		Owner:         pinned.Owner,
+		Repo:          pinned.Repo,
+		Path:          pinned.Path,
+		Ref:           c.Ref,
+		OtherRefNames: c.OtherRefNames,
+	}
+}
+
+// mostSpecificVersion returns the most specific semver tag of names, e.g.
+// v3.5.3 of v3, v3.5.3 and main.
+func mostSpecificVersion(names []string) (*utils.Semver, bool) {
+	var best *utils.Semver
+	for _, name := range names {
+		version, ok := utils.ParseSemver(name)
+		if !ok {
+			continue
+		}
+		if best == nil || version.Precision > best.Precision || (version.Precision == best.Precision && version.Compare(best) > 0) {
+			best = version
+		}
+	}
+	return best, best != nil
+}
+
+// version returns the release the pin tracks: the ref of the comment if it
+// is a version, so a pin of v3 keeps following major versions, or the most
+// specific semver tag among the other refs it names.
+func (c *pinComment) version() (*utils.Semver, bool) {
+	if version, ok := utils.ParseSemver(c.Ref); ok {
+		return version, true
+	}
+	return mostSpecificVersion(c.OtherRefNames)
+}
+
+// isFloatingRef reports whether a tag or branch is expected to move, like a
+// branch or a major or minor version tag such as v3 or v3.5.
+func isFloatingRef(name string, refType string) bool {
+	if refType == "branch" {
+		return true
+	}
+	version, ok := utils.ParseSemver(name)
+	return ok && version.Precision < 3
+}
+
+// pinCommentCache holds the warnings of verified comments, keyed by the
+// pinned action and the comment.
+var pinCommentCache utils.Memo[utils.Warnings]
+
+// verifyPinComment re-resolves the refs named in the trailing comment of an
+// action pinned to a commit SHA and warns when a fixed tag has moved since
+// pinning, or when a ref does not contain the pinned commit at all, which
+// means the comment is misleading or the ref was moved to unrelated history.
+// The warnings are returned rather than printed.
+func verifyPinComment(githubActionRef *GithubActionRef, comment *pinComment) (utils.Warnings, error) {
+	cacheKey := fmt.Sprintf("%s/%s@%s # %s %s | %s", githubActionRef.Owner, githubActionRef.Repo, githubActionRef.Ref, comment.Action, comment.Ref, strings.Join(comment.OtherRefNames, ","))
+	return pinCommentCache.Do(cacheKey, func() (utils.Warnings, error) {
+		warnings := utils.Warnings{}
+		err := checkPinComment(githubActionRef, comment, &warnings)
+		return warnings, err
+	})
+}
+
+func checkPinComment(githubActionRef *GithubActionRef, comment *pinComment, warnings *utils.Warnings) error {
+	owner := githubActionRef.Owner
+	repo := githubActionRef.Repo
+	sha := githubActionRef.Ref
+
+	// comments naming the ref only are about the pinned action
+	if comment.Action != "" {
+		commentActionRef, err := parseActionString(comment.Action)
+		if err != nil {
+			return nil
+		}
+		if !strings.EqualFold(commentActionRef.Owner, owner) || !strings.EqualFold(commentActionRef.Repo, repo) {
+			warnings.Warnf("Comment names %s but %s/%s@%s is pinned\n", comment.Action, owner, repo, sha)
+			return nil
+		}
+	}
+
+	client, err := getGithubClient(owner)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	for _, name := range append([]string{comment.Ref}, comment.OtherRefNames...) {
+		if shortSHARegex.MatchString(name) || isFullSHA(name) {
+			if !strings.HasPrefix(sha, name) {
+				warnings.Warnf("Comment names commit %s but %s/%s@%s is pinned\n", name, owner, repo, sha)
+			}
+			continue
+		}
+
+		exactRef, refType, _, err := findRef(ctx, client, owner, repo, name)
+		if err != nil {
+			return err
+		}
+		if exactRef == nil {
+			warnings.Warnf("%s/%s@%s named in the comment of pinned commit %s no longer exists\n", owner, repo, name, sha)
+			continue
+		}
+		digest, err := dereference(ctx, client, owner, repo, exactRef)
+		if err != nil {
+			return err
+		}
+		if digest == sha {
+			continue
+		}
+
+		contained, err := refContains(ctx, client, owner, repo, digest, sha)
+		if err != nil {
+			return err
+		}
+		if !contained {
+			warnings.Warnf("%s/%s@%s never pointed to pinned commit %s, the comment is misleading or the %s was moved to unrelated history\n", owner, repo, name, sha, refType)
+		} else if !isFloatingRef(name, refType) {
+			warnings.Warnf("Tag %s/%s@%s has moved from pinned commit %s to %s. A moved tag is a strong sign of a compromised repository\n", owner, repo, name, sha, digest)
+		}
+	}
+	return nil
+}
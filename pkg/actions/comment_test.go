@@ -0,0 +1,81 @@
+package actions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePinComment(t *testing.T) {
+	tests := []struct {
+		comment string
+		ok      bool
+		want    pinComment
+	}{
+		{"v3.5.3 | v3", true, pinComment{Ref: "v3", OtherRefNames: []string{"v3.5.3"}}},
+		{"v3.5.3 | main", true, pinComment{Ref: "main", OtherRefNames: []string{"v3.5.3"}}},
+		{"v3.5.3", true, pinComment{Ref: "v3.5.3", OtherRefNames: []string{}}},
+		{"tag=v3.5.3", true, pinComment{Ref: "v3.5.3", OtherRefNames: []string{}}},
+		{"actions/checkout@v3 | v3.5.3,v3.5", true, pinComment{Action: "actions/checkout@v3", Ref: "v3", OtherRefNames: []string{"v3.5.3", "v3.5"}}},
+		{"actions/checkout@main", true, pinComment{Action: "actions/checkout@main", Ref: "main", OtherRefNames: []string{}}},
+		{"checkout the repo", false, pinComment{}},
+		{"main", false, pinComment{}},
+		{"see | below", false, pinComment{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.comment, func(t *testing.T) {
+			got, ok := parsePinComment(tt.comment)
+			if ok != tt.ok {
+				t.Fatalf("parsePinComment(%q) ok = %v, want %v", tt.comment, ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("parsePinComment(%q) = %+v, want %+v", tt.comment, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPinComment(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		ref       string
+		otherRefs []string
+		want      string
+	}{
+		{"default", DefaultCommentTemplate, "v3", []string{"v3.5", "v3.5.3"}, "v3.5.3 | v3"},
+		{"default pinned to the version", DefaultCommentTemplate, "v3.5.3", []string{"v3", "v3.5"}, "v3.5.3"},
+		{"default branch", DefaultCommentTemplate, "main", []string{"v3.5.3"}, "v3.5.3 | main"},
+		{"default without version", DefaultCommentTemplate, "main", []string{}, "actions/checkout@main"},
+		{"version only", "{version}", "v3", []string{"v3.5.3"}, "v3.5.3"},
+		{"renovate", "tag={version}", "v3", []string{"v3.5.3"}, "tag=v3.5.3"},
+		{"legacy", LegacyCommentTemplate, "v3", []string{"v3.5.3"}, "actions/checkout@v3 | v3.5.3"},
+		{"legacy without other refs", LegacyCommentTemplate, "v3", []string{}, "actions/checkout@v3"},
+	}
+	defer func(template string) {
+		CommentTemplate = template
+	}(CommentTemplate)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CommentTemplate = tt.template
+			githubActionRef := &GithubActionRef{
+				Raw:           "actions/checkout@" + tt.ref,
+				Owner:         "actions",
+				Repo:          "checkout",
+				Ref:           tt.ref,
+				OtherRefNames: tt.otherRefs,
+			}
+			comment := formatPinComment(githubActionRef)
+			if comment != tt.want {
+				t.Fatalf("formatPinComment() = %q, want %q", comment, tt.want)
+			}
+			// the default and legacy comments are read back as the ref that
+			// was pinned, the version only templates name the version alone
+			if tt.template != DefaultCommentTemplate && tt.template != LegacyCommentTemplate {
+				return
+			}
+			if parsed, ok := parsePinComment(comment); !ok || parsed.Ref != tt.ref {
+				t.Errorf("parsePinComment(%q) = %+v, %v, want ref %s", comment, parsed, ok, tt.ref)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+const (
+	// The repo of the action is archived and gets no more fixes
+	DeprecatedArchived = "archived"
+	// The repo of the action is disabled by Github
+	DeprecatedDisabled = "disabled"
+	// The action runs on a node version Github Actions no longer supports
+	DeprecatedRuntime = "runtime"
+)
+
+// Runtimes that Github Actions has retired, actions using them are forced
+// onto a newer node version or fail
+var deprecatedRuntimes = map[string]bool{
+	"node12": true,
+	"node16": true,
+}
+
+// Maintained replacements of archived actions, keyed by owner/repo in lower
+// case
+var knownReplacements = map[string]string{
+	"actions/create-release":           "softprops/action-gh-release",
+	"actions/upload-release-asset":     "softprops/action-gh-release",
+	"actions/setup-ruby":               "ruby/setup-ruby",
+	"actions/setup-elixir":             "erlef/setup-beam",
+	"actions/setup-haskell":            "haskell-actions/setup",
+	"haskell/actions":                  "haskell-actions/setup",
+	"actions-rs/toolchain":             "dtolnay/rust-toolchain",
+	"actions-rs/audit-check":           "rustsec/audit-check",
+	"crazy-max/ghaction-docker-buildx": "docker/setup-buildx-action",
+}
+
+var repoCache utils.Memo[*github.Repository]
+
+// supportedReleaseCache maps owner/repo/path in lower case to the release
+// supportedRelease found for it
+var supportedReleaseCache utils.Memo[string]
+
+// Deprecation is a reason an action should no longer be used. Replacement
+// is a maintained action or release to move to, if one is known.
+type Deprecation struct {
+	Reason      string `json:"reason"`
+	Message     string `json:"message"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+func (d *Deprecation) String() string {
+	if d.Replacement == "" {
+		return d.Message
+	}
+	return fmt.Sprintf("%s, replace with %s", d.Message, d.Replacement)
+}
+
+func getRepository(ctx context.Context, owner string, repo string) (*github.Repository, error) {
+	return repoCache.Do(strings.ToLower(fmt.Sprintf("%s/%s", owner, repo)), func() (*github.Repository, error) {
+		client, err := getGithubClient(owner)
+		if err != nil {
+			return nil, err
+		}
+		repository, resp, err := rateLimited(ctx, func() (*github.Repository, *github.Response, error) {
+			return client.Repositories.Get(ctx, owner, repo)
+		})
+		recordRedirect(owner, repo, resp)
+		return repository, err
+	})
+}
+
+// checkDeprecations adds the deprecations of a resolved action: an archived
+// or disabled repo, and a manifest at the resolved SHA that runs on a
+// retired node version.
+func (a *auditor) checkDeprecations(dep *ActionDependency, githubActionRef *GithubActionRef) {
+	name := strings.ToLower(fmt.Sprintf("%s/%s", githubActionRef.Owner, githubActionRef.Repo))
+	repository, err := getRepository(a.ctx, githubActionRef.Owner, githubActionRef.Repo)
+	if err != nil {
+		utils.Warnf("Could not fetch the repo of %s: %s\n", githubActionRef.NameWithRef(), err)
+	} else if repository.GetDisabled() {
+		dep.addDeprecation(&Deprecation{
+			Reason:      DeprecatedDisabled,
+			Message:     "repo is disabled",
+			Replacement: knownReplacements[name],
+		})
+		return
+	} else if repository.GetArchived() {
+		dep.addDeprecation(&Deprecation{
+			Reason:      DeprecatedArchived,
+			Message:     "repo is archived and no longer maintained",
+			Replacement: knownReplacements[name],
+		})
+	}
+
+	if isReusableWorkflow(githubActionRef) {
+		return
+	}
+	content, _, err := a.actionManifest(githubActionRef)
+	if err != nil {
+		// reported by the transitive walk, which needs the manifest too
+		if !a.transitive {
+			utils.Warnf("%s\n", err)
+		}
+		return
+	}
+	runtime, err := findActionRuntime(content)
+	if err != nil || !deprecatedRuntimes[runtime] {
+		return
+	}
+	replacement := knownReplacements[name]
+	if replacement == "" {
+		replacement = a.supportedRelease(githubActionRef)
+	}
+	dep.addDeprecation(&Deprecation{
+		Reason:      DeprecatedRuntime,
+		Message:     fmt.Sprintf("runs on %s, which Github Actions no longer supports", runtime),
+		Replacement: replacement,
+	})
+}
+
+// supportedRelease returns the newest release of an action if it runs on a
+// supported runtime, like actions/checkout@v4.1.1, or an empty string. It is
+// looked up once per action, however often the action is used.
+func (a *auditor) supportedRelease(githubActionRef *GithubActionRef) string {
+	cacheKey := strings.ToLower(path.Join(githubActionRef.Owner, githubActionRef.Repo, githubActionRef.Path))
+	release, _ := supportedReleaseCache.Do(cacheKey, func() (string, error) {
+		client, err := getGithubClient(githubActionRef.Owner)
+		if err != nil {
+			return "", nil
+		}
+		tags, err := listMatchingRefs(a.ctx, client, githubActionRef.Owner, githubActionRef.Repo, "tags")
+		if err != nil {
+			return "", nil
+		}
+		var newest *utils.Semver
+		for _, tag := range tags {
+			version, ok := utils.ParseSemver(strings.TrimPrefix(tag.GetRef(), "refs/tags/"))
+			if !ok || version.Prerelease != "" {
+				continue
+			}
+			if newest == nil || version.Compare(newest) > 0 {
+				newest = version
+			}
+		}
+		if newest == nil {
+			return "", nil
+		}
+
+		latest := *githubActionRef
+		latest.Ref = newest.Raw
+		latest.Digest = newest.Raw
+		content, _, err := a.actionManifest(&latest)
+		if err != nil {
+			return "", nil
+		}
+		runtime, err := findActionRuntime(content)
+		if err != nil || deprecatedRuntimes[runtime] {
+			return "", nil
+		}
+		return latest.NameWithRef(), nil
+	})
+	return release
+}
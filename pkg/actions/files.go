@@ -0,0 +1,180 @@
+package actions
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPaths are searched for uses: references unless Paths is set:
+// workflows, local composite actions, org workflow templates and the
+// manifest of an action published from the repository root.
+var defaultPaths = []string{
+	workflowDir,
+	".github/actions",
+	"workflow-templates",
+	"action.yml",
+	"action.yaml",
+}
+
+// Paths are the files and directories searched for uses: references, set
+// with --path. Directories are searched recursively.
+var Paths []string
+
+// UsesFile is a workflow or action manifest with uses: references, or the
+// Dockerfile a docker container action is built from.
+type UsesFile struct {
+	Path string
+	// Action is set for action manifests, whose references live under
+	// runs instead of jobs
+	Action bool
+	// Dockerfile is set for the Dockerfiles named by the runs.image of
+	// action manifests, which are pinned like pinny docker pin does
+	Dockerfile bool
+}
+
+func isYAMLFile(name string) bool {
+	return strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml")
+}
+
+func isActionManifest(name string) bool {
+	return name == "action.yml" || name == "action.yaml"
+}
+
+// inLocalActions reports whether path lies below a .github/actions
+// directory.
+func inLocalActions(path string) bool {
+	return strings.Contains(filepath.ToSlash(path), ".github/actions/")
+}
+
+// FindUsesFiles returns the workflows and action manifests found in Paths,
+// or in the default locations if Paths is empty. Default locations that
+// don't exist are skipped, paths given with --path must exist.
+func FindUsesFiles() ([]*UsesFile, error) {
+	paths := Paths
+	if len(paths) == 0 {
+		paths = defaultPaths
+	}
+
+	seen := make(map[string]bool)
+	files := []*UsesFile{}
+	add := func(file string) {
+		file = filepath.Clean(file)
+		if seen[file] {
+			return
+		}
+		seen[file] = true
+		files = append(files, &UsesFile{
+			Path:   file,
+			Action: isActionManifest(filepath.Base(file)),
+		})
+	}
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if os.IsNotExist(err) && len(Paths) == 0 {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && utils.SkippedDirs[d.Name()] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// local actions keep their scripts and configs next to the
+			// manifest, only the manifest holds uses: references
+			if isActionManifest(d.Name()) || (isYAMLFile(d.Name()) && !inLocalActions(path)) {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the Dockerfiles of docker container actions, which the actions
+	// listed above can't be told from without reading them
+	for _, file := range files {
+		if !file.Action {
+			continue
+		}
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		image, err := findActionDockerfile(content)
+		if err != nil || image == "" {
+			continue
+		}
+		dockerfile := filepath.Join(filepath.Dir(file.Path), image)
+		if !seen[dockerfile] {
+			seen[dockerfile] = true
+			files = append(files, &UsesFile{
+				Path:       dockerfile,
+				Dockerfile: true,
+			})
+		}
+	}
+	return files, nil
+}
+
+// NoUsesFilesError is the error of commands that need at least one workflow
+// or action manifest to work on.
+func NoUsesFilesError() error {
+	paths := Paths
+	if len(paths) == 0 {
+		paths = defaultPaths
+	}
+	return fmt.Errorf("no workflows or actions found in %s", strings.Join(paths, ", "))
+}
+
+// images returns the job container and service images of a workflow.
+// Action manifests have none.
+func (f *UsesFile) images(content []byte) ([]*yaml.Node, error) {
+	if f.Action || f.Dockerfile {
+		return []*yaml.Node{}, nil
+	}
+	imageNodes, err := findWorkflowImageNodes(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	return imageNodes, nil
+}
+
+// read returns the content of the file and its uses: references.
+// Dockerfiles have none.
+func (f *UsesFile) read() ([]byte, []*yaml.Node, error) {
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.Dockerfile {
+		return content, []*yaml.Node{}, nil
+	}
+	var usesNodes []*yaml.Node
+	if f.Action {
+		usesNodes, err = findActionUsesNodes(content)
+	} else {
+		usesNodes, err = findWorkflowUsesNodes(content)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	return content, usesNodes, nil
+}
@@ -0,0 +1,139 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/koalalab-inc/pinny/pkg/cache"
+)
+
+// cachedHeaders are the response headers kept in the cache, go-github needs
+// Link to follow pagination.
+var cachedHeaders = []string{"Content-Type", "Link", "ETag", "Last-Modified"}
+
+// Responses telling where refs point and which commits they contain change
+// whenever a tag is moved or a branch pushed to. They are revalidated on
+// every request, so branch pins, moved tags and impostor commits are judged
+// on current data.
+var revalidatedPathRegex = regexp.MustCompile(`/(git/matching-refs|git/refs?|compare)/`)
+
+type cachedResponse struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func (c *cachedResponse) response(req *http.Request) *http.Response {
+	header := c.Header.Clone()
+	// go-github does not take the rate limit from responses marked like this
+	header.Set("X-From-Cache", "1")
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+// cacheTransport serves GET requests to the Github API from the on-disk
+// cache while the cached response has not expired, and revalidates expired
+// responses, and the responses about refs every time, with a conditional
+// request. Github does not count a 304 Not Modified response against the
+// rate limit.
+type cacheTransport struct {
+	base http.RoundTripper
+	// credential identifies the credential requests are sent with, see
+	// credentialID
+	credential string
+}
+
+// responseCacheKey keys responses by URL and by the credential used. The
+// token itself is not stored.
+func (t *cacheTransport) responseCacheKey(req *http.Request) string {
+	return fmt.Sprintf("%s %s", t.credential, req.URL.String())
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the rate limit is the one response that must always be current
+	if req.Method != http.MethodGet || cache.Disabled || strings.HasSuffix(req.URL.Path, "/rate_limit") {
+		return t.base.RoundTrip(req)
+	}
+
+	key := t.responseCacheKey(req)
+	entry, ok := cache.Get(cache.KindGithub, key)
+	cached := &cachedResponse{}
+	if ok && json.Unmarshal(entry.Value, cached) != nil {
+		ok = false
+	}
+	if ok && !entry.Expired() && !revalidatedPathRegex.MatchString(req.URL.Path) {
+		return cached.response(req), nil
+	}
+
+	if ok && (entry.ETag != "" || entry.LastModified != "") {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		entry.StoredAt = time.Now()
+		entry.ExpiresAt = entry.StoredAt.Add(cache.TTL)
+		cache.Put(entry)
+		return cached.response(req), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	cached = &cachedResponse{
+		Header: http.Header{},
+		Body:   body,
+	}
+	for _, name := range cachedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			cached.Header.Set(name, value)
+		}
+	}
+	value, err := json.Marshal(cached)
+	if err != nil {
+		return resp, nil
+	}
+	now := time.Now()
+	cache.Put(&cache.Entry{
+		Kind:         cache.KindGithub,
+		Key:          key,
+		Value:        value,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		StoredAt:     now,
+		ExpiresAt:    now.Add(cache.TTL),
+	})
+	return resp, nil
+}
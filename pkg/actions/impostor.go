@@ -0,0 +1,140 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+const (
+	// The commit is reachable from a branch or tag of the upstream repo
+	ReachableUpstream = "upstream"
+	// The commit only exists in the fork network of the repo
+	ReachableForkNetwork = "fork-network"
+	// The commit does not exist at all
+	ReachableMissing = "missing"
+	// No ref containing the commit was found within ImpostorBudget
+	ReachableInconclusive = "inconclusive"
+)
+
+// ImpostorBudget caps the comparisons made to find a ref containing a
+// pinned commit, 0 means no limit. Comparisons answered from memory are
+// free.
+var ImpostorBudget = 25
+
+// checkImpostor reports whether commit sha of owner/repo is reachable from
+// one of the repo's branches or tags. Github serves commits pushed to any
+// fork through the parent repo, so a commit that no upstream ref contains is
+// an impostor. See docs/impostorcommits.md. The default branch is compared
+// first, as it holds most commits actions are pinned to, and only when it
+// does not contain the commit are the other refs of the repo listed. When
+// the budget runs out before a ref containing the commit is found, the
+// result is inconclusive.
+func checkImpostor(ctx context.Context, client *github.Client, owner string, repo string, sha string) (string, error) {
+	calls := 0
+	// compare reports whether rRef contains the commit, ok is false once the
+	// budget is spent
+	compare := func(rRef string) (contained bool, ok bool, err error) {
+		_, memoised := refContainsCache.Get(refContainsKey(owner, repo, rRef, sha))
+		if !memoised {
+			if ImpostorBudget > 0 && calls >= ImpostorBudget {
+				return false, false, nil
+			}
+			calls++
+		}
+		contained, err = refContains(ctx, client, owner, repo, rRef, sha)
+		return contained, true, err
+	}
+
+	defaultBranch := ""
+	if repository, err := getRepository(ctx, owner, repo); err == nil && repository.GetDefaultBranch() != "" {
+		defaultBranch = fmt.Sprintf("refs/heads/%s", repository.GetDefaultBranch())
+		contained, _, err := compare(defaultBranch)
+		if err != nil {
+			return "", err
+		}
+		if contained {
+			return ReachableUpstream, nil
+		}
+	}
+
+	exhausted := ImpostorBudget > 0 && calls >= ImpostorBudget
+	if !exhausted {
+		refs, err := listRepoRefs(ctx, client, owner, repo)
+		if err != nil {
+			return "", err
+		}
+		// A ref pointing straight at the commit needs no comparison
+		for _, r := range refs {
+			if r.GetObject().GetType() == "commit" && r.GetObject().GetSHA() == sha {
+				return ReachableUpstream, nil
+			}
+		}
+		for _, rRef := range containmentCandidates(refs, defaultBranch) {
+			contained, ok, err := compare(rRef)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				exhausted = true
+				break
+			}
+			if contained {
+				return ReachableUpstream, nil
+			}
+		}
+	}
+
+	_, resp, err := rateLimited(ctx, func() (*github.Commit, *github.Response, error) {
+		return client.Git.GetCommit(ctx, owner, repo, sha)
+	})
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity) {
+			return ReachableMissing, nil
+		}
+		return "", err
+	}
+	if exhausted {
+		return ReachableInconclusive, nil
+	}
+	return ReachableForkNetwork, nil
+}
+
+// containmentCandidates orders the refs other than the default branch to
+// compare a commit against so the ones most likely to contain it come
+// first: release tags newest first, as a newer release contains the commits
+// of older ones, then every other tag and branch.
+func containmentCandidates(refs []*github.Reference, defaultBranch string) []string {
+	candidates := []string{}
+	releases := []*utils.Semver{}
+	otherTags := []string{}
+	branches := []string{}
+	for _, r := range refs {
+		rRef := r.GetRef()
+		switch {
+		case rRef == defaultBranch:
+			continue
+		case strings.HasPrefix(rRef, "refs/tags/"):
+			if version, ok := utils.ParseSemver(strings.TrimPrefix(rRef, "refs/tags/")); ok {
+				releases = append(releases, version)
+			} else {
+				otherTags = append(otherTags, rRef)
+			}
+		case strings.HasPrefix(rRef, "refs/heads/"):
+			branches = append(branches, rRef)
+		}
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].Compare(releases[j]) > 0
+	})
+	for _, version := range releases {
+		candidates = append(candidates, fmt.Sprintf("refs/tags/%s", version.Raw))
+	}
+	candidates = append(candidates, otherTags...)
+	return append(candidates, branches...)
+}
@@ -0,0 +1,193 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/koalalab-inc/pinny/pkg/docker"
+	"github.com/koalalab-inc/pinny/pkg/policy"
+)
+
+const Lockfile = "pinny-actions-lock.json"
+
+type LockedAction struct {
+	Digest        string   `json:"digest"`
+	OtherRefNames []string `json:"other_refs,omitempty"`
+}
+
+// ActionsLock maps the action refs and docker images used in workflows to
+// the digests they resolved to, so workflows can be pinned without calling
+// the Github API.
+type ActionsLock struct {
+	Actions     map[string]*LockedAction `json:"actions"`
+	Images      map[string]string        `json:"images"`
+	GeneratedAt string                   `json:"generated_at"`
+	GeneratedBy string                   `json:"generated_by"`
+}
+
+func lockKey(githubActionRef *GithubActionRef) string {
+	return fmt.Sprintf("%s/%s@%s", githubActionRef.Owner, githubActionRef.Repo, githubActionRef.Ref)
+}
+
+func readLockfile() (*ActionsLock, error) {
+	lockFileContents, err := os.ReadFile(Lockfile)
+	if err != nil {
+		return nil, err
+	}
+	lock := &ActionsLock{}
+	err = json.Unmarshal(lockFileContents, lock)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", Lockfile, err)
+	}
+	if lock.Actions == nil {
+		lock.Actions = make(map[string]*LockedAction)
+	}
+	if lock.Images == nil {
+		lock.Images = make(map[string]string)
+	}
+	return lock, nil
+}
+
+// actionRef returns the locked digest of actionString. Actions that are
+// already pinned to a commit SHA need no entry in the lock file.
+func (l *ActionsLock) actionRef(actionString string) (*GithubActionRef, error) {
+	githubActionRef, err := parseActionString(actionString)
+	if err != nil {
+		return nil, err
+	}
+	if isFullSHA(githubActionRef.Ref) {
+		githubActionRef.Digest = githubActionRef.Ref
+		return githubActionRef, nil
+	}
+	locked, ok := l.Actions[lockKey(githubActionRef)]
+	if !ok {
+		return nil, fmt.Errorf("digest not found for %s in %s", lockKey(githubActionRef), Lockfile)
+	}
+	githubActionRef.Digest = locked.Digest
+	githubActionRef.OtherRefNames = locked.OtherRefNames
+	return githubActionRef, nil
+}
+
+func (l *ActionsLock) imageRef(imageString string) (*docker.DockerImageRef, error) {
+	dockerImageRef, err := docker.ParseImageRef(imageString)
+	if err != nil {
+		return nil, err
+	}
+	if dockerImageRef.Digest != "" {
+		return dockerImageRef, nil
+	}
+	digest, ok := l.Images[imageString]
+	if !ok {
+		return nil, fmt.Errorf("digest not found for %s in %s", imageString, Lockfile)
+	}
+	dockerImageRef.Digest = digest
+	return dockerImageRef, nil
+}
+
+// GeneratePinnyLockFile resolves every action ref, docker image and job
+// container and service image used in the workflows and action manifests
+// and records them in the lock file. Entries of an existing lock file are
+// kept.
+func GeneratePinnyLockFile() error {
+	lock, err := readLockfile()
+	if os.IsNotExist(err) {
+		lock = &ActionsLock{
+			Actions: make(map[string]*LockedAction),
+			Images:  make(map[string]string),
+		}
+	} else if err != nil {
+		return err
+	}
+
+	files, err := FindUsesFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return NoUsesFilesError()
+	}
+	p, err := policy.Load()
+	if err != nil {
+		return err
+	}
+	prefetchWorkflows(true, false)
+
+	for _, file := range files {
+		// the images of action Dockerfiles go to the docker lock file, which
+		// pinny actions transform pins them from
+		if file.Dockerfile {
+			err = docker.GeneratePinnyLockFile(file.Path)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		content, usesNodes, err := file.read()
+		if err != nil {
+			return err
+		}
+		for _, node := range usesNodes {
+			actionString := node.Value
+			if strings.HasPrefix(actionString, "docker://") {
+				if strings.Contains(actionString, "@sha256:") {
+					continue
+				}
+				dockerImageRef, err := docker.GetImageRefWithDigest(actionString)
+				if err != nil {
+					return err
+				}
+				lock.Images[actionString] = dockerImageRef.Digest
+			} else if strings.Contains(actionString, "@") {
+				// trusted actions stay on their tags and need no entry
+				if trustedUses(p, actionString) {
+					continue
+				}
+				githubActionRef, err := GetGithubActionRefWithDigest(actionString)
+				if err != nil {
+					return err
+				}
+				if isFullSHA(githubActionRef.Ref) {
+					continue
+				}
+				lock.Actions[lockKey(githubActionRef)] = &LockedAction{
+					Digest:        githubActionRef.Digest,
+					OtherRefNames: githubActionRef.OtherRefNames,
+				}
+			}
+		}
+
+		imageNodes, err := file.images(content)
+		if err != nil {
+			return err
+		}
+		for _, node := range imageNodes {
+			imageString := node.Value
+			if strings.Contains(imageString, "@sha256:") {
+				continue
+			}
+			dockerImageRef, err := docker.GetImageRefWithDigest(imageString)
+			if err != nil {
+				return err
+			}
+			lock.Images[imageString] = dockerImageRef.Digest
+		}
+	}
+
+	lock.GeneratedAt = time.Now().Format(time.RFC1123)
+	lock.GeneratedBy = "Pinny"
+
+	lockJSON, err := json.MarshalIndent(lock, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmpLockFile := fmt.Sprintf("%s.tmp", Lockfile)
+	err = os.WriteFile(tmpLockFile, lockJSON, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpLockFile, Lockfile)
+}
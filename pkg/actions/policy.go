@@ -0,0 +1,98 @@
+package actions
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/findings"
+	"github.com/koalalab-inc/pinny/pkg/policy"
+	"gopkg.in/yaml.v3"
+)
+
+// usesViolation returns the policy violation of a uses: reference, or nil if
+// the policy allows it. refType is "branch" if the ref is known to be a
+// branch.
+func usesViolation(p *policy.Policy, uses string, refType string) *policy.Violation {
+	if strings.HasPrefix(uses, "docker://") {
+		return p.CheckImage(uses)
+	}
+	githubActionRef, err := parseActionString(uses)
+	if err != nil {
+		return nil
+	}
+	return p.CheckAction(githubActionRef.Owner, githubActionRef.Repo, githubActionRef.Path, githubActionRef.Ref, refType)
+}
+
+// imageViolation returns the policy violation of a docker:// reference or a
+// job container or service image, or nil if the policy allows it. An image
+// without a digest breaks the policy if its registry requires one.
+func imageViolation(p *policy.Policy, image string) *policy.Violation {
+	if violation := p.CheckImage(image); violation != nil {
+		return violation
+	}
+	if strings.Contains(image, "@sha256:") {
+		return nil
+	}
+	if rule := p.DigestRule(image); rule != nil {
+		return &policy.Violation{
+			Rule:    rule,
+			Message: "image is not pinned to a digest",
+		}
+	}
+	return nil
+}
+
+// signatureViolation returns the policy violation of an action resolved
+// against Github whose tag is not signed, or nil if the policy allows it.
+// Whether the other releases of the action are signed is only looked up
+// for unsigned tags, when the policy asks for it.
+func signatureViolation(p *policy.Policy, githubActionRef *GithubActionRef) *policy.Violation {
+	if githubActionRef.Signature == nil {
+		return nil
+	}
+	return p.CheckSignature(githubActionRef.Owner, githubActionRef.Repo, githubActionRef.Path, githubActionRef.Ref, githubActionRef.RefType, githubActionRef.Signature.Verified(), func() bool {
+		return signsReleases(githubActionRef)
+	})
+}
+
+// signatureRule returns the rule that requires an action to be signed, for
+// when the signature can't be looked up.
+func signatureRule(p *policy.Policy, githubActionRef *GithubActionRef) *policy.Rule {
+	if isFullSHA(githubActionRef.Ref) {
+		return nil
+	}
+	return p.SignatureRule(githubActionRef.Owner, githubActionRef.Repo, githubActionRef.Path, githubActionRef.Ref)
+}
+
+// trustedUses reports whether the policy lets an action reference stay on a
+// tag instead of being pinned.
+func trustedUses(p *policy.Policy, uses string) bool {
+	githubActionRef, err := parseActionString(uses)
+	if err != nil || isFullSHA(githubActionRef.Ref) {
+		return false
+	}
+	return p.TrustedAction(githubActionRef.Owner, githubActionRef.Repo, githubActionRef.Path, githubActionRef.Ref) != nil
+}
+
+// guessRefType returns "branch" for refs that are almost always branches,
+// for when Github can't be asked.
+func guessRefType(ref string) string {
+	if wellKnownBranches[ref] {
+		return "branch"
+	}
+	return ""
+}
+
+func violationFinding(file string, node *yaml.Node, violation *policy.Violation) *findings.Finding {
+	return &findings.Finding{
+		File:    file,
+		Line:    node.Line,
+		Ref:     node.Value,
+		Message: violation.Message,
+		Rule:    violation.Rule.String(),
+	}
+}
+
+func violationError(file string, node *yaml.Node, violation *policy.Violation) error {
+	return errors.New(violationFinding(file, node, violation).String())
+}
@@ -0,0 +1,98 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/docker"
+	"github.com/koalalab-inc/pinny/pkg/policy"
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+// PrefetchWorkflows resolves every unique action and docker image used in
+// the workflows and action manifests concurrently, and verifies the comments
+// of pinned actions, so that pinning the files one after another afterwards
+// is served from the caches.
+func PrefetchWorkflows() {
+	prefetchWorkflows(true, true)
+}
+
+// prefetchWorkflows resolves every unique action used in the workflows and
+// action manifests concurrently, along with the docker, job container and
+// service images if images is set and the refs named in the comments of
+// pinned actions if verifyComments is set.
+// Errors are ignored here, they are returned again when the failed lookup is
+// retried in order.
+func prefetchWorkflows(images bool, verifyComments bool) {
+	files, err := FindUsesFiles()
+	if err != nil {
+		return
+	}
+
+	p, err := policy.Load()
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	tasks := []func(){}
+	add := func(key string, task func()) {
+		if !seen[key] {
+			seen[key] = true
+			tasks = append(tasks, task)
+		}
+	}
+
+	for _, file := range files {
+		content, usesNodes, err := file.read()
+		if err != nil {
+			continue
+		}
+		for _, node := range usesNodes {
+			actionString := node.Value
+			if strings.HasPrefix(actionString, "docker://") {
+				if !images || strings.Contains(actionString, "@sha256:") {
+					continue
+				}
+				add(actionString, func() {
+					docker.GetImageRefWithDigest(actionString)
+				})
+				continue
+			}
+			githubActionRef, err := parseActionString(actionString)
+			if err != nil || trustedUses(p, actionString) {
+				continue
+			}
+			add(lockKey(githubActionRef), func() {
+				getResolvedActionRef(actionString)
+			})
+			if !verifyComments || !isFullSHA(githubActionRef.Ref) {
+				continue
+			}
+			if comment, ok := parsePinComment(lineComment(content, node)); ok {
+				add(fmt.Sprintf("%s # %s", lockKey(githubActionRef), lineComment(content, node)), func() {
+					verifyPinComment(githubActionRef, comment)
+				})
+			}
+		}
+
+		imageNodes, err := file.images(content)
+		if !images || err != nil {
+			continue
+		}
+		for _, node := range imageNodes {
+			imageString := node.Value
+			if strings.Contains(imageString, "@sha256:") {
+				continue
+			}
+			add(imageString, func() {
+				docker.GetImageRefWithDigest(imageString)
+			})
+		}
+	}
+
+	utils.ForEach(len(tasks), func(i int) error {
+		tasks[i]()
+		return nil
+	})
+}
@@ -0,0 +1,212 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+const (
+	// maxRetries bounds how often a request is retried after a rate limit or
+	// a transient server error.
+	maxRetries = 5
+	// secondaryRateLimitWait is how long to wait after a secondary rate limit
+	// that came without a Retry-After header, as recommended by Github.
+	secondaryRateLimitWait = time.Minute
+	serverErrorWait        = time.Second
+)
+
+// Quota is the API quota of a Github host as reported by its most recent
+// response.
+type Quota struct {
+	Host      string
+	Resource  string
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	Requests  int
+}
+
+// quotas holds the quota of every host and rate limit resource, keyed by
+// host/resource.
+var quotas = make(map[string]*Quota)
+var quotasMu sync.Mutex
+
+// rateLimitTransport retries requests that hit a primary or secondary rate
+// limit once the limit has reset, and requests that failed with a transient
+// server error after an exponential backoff. It also records the remaining
+// quota of every host it talks to.
+type rateLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("cannot retry %s %s, the request body cannot be replayed", req.Method, req.URL.Redacted())
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		quota := recordQuota(req, resp)
+
+		wait, reason, err := retryAfter(resp, quota, attempt)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if wait < 0 || attempt >= maxRetries {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		utils.Warnf("%s for %s, retrying in %s\n", reason, req.URL.Host, wait.Round(time.Second))
+		err = sleep(req.Context(), wait)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimited runs call, a request made with go-github, and runs it again
+// once the quota has reset if go-github refused to send the request because
+// an earlier response used up the quota. Limits hit by requests that were
+// sent are waited out by rateLimitTransport.
+func rateLimited[T any](ctx context.Context, call func() (T, *github.Response, error)) (T, *github.Response, error) {
+	for attempt := 0; ; attempt++ {
+		result, resp, err := call()
+		var rateLimitErr *github.RateLimitError
+		if !errors.As(err, &rateLimitErr) || attempt >= maxRetries {
+			return result, resp, err
+		}
+		wait := untilReset(rateLimitErr.Rate.Reset.Time)
+		utils.Warnf("Rate limit of %d requests exceeded for %s, retrying in %s\n", rateLimitErr.Rate.Limit, rateLimitErr.Response.Request.URL.Host, wait.Round(time.Second))
+		err = sleep(ctx, wait)
+		if err != nil {
+			return result, resp, err
+		}
+	}
+}
+
+// untilReset returns how long to wait for a quota to reset at reset. The
+// reset time has a resolution of seconds, so one more is waited to not race
+// the reset.
+func untilReset(reset time.Time) time.Duration {
+	return max(time.Until(reset), 0) + time.Second
+}
+
+// retryAfter decides whether resp should be retried. It returns how long to
+// wait before doing so, or a negative duration if resp is final.
+func retryAfter(resp *http.Response, quota *Quota, attempt int) (time.Duration, string, error) {
+	switch {
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return serverErrorWait << attempt, fmt.Sprintf("Server error %d", resp.StatusCode), nil
+	case resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests:
+		return -1, "", nil
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, "Secondary rate limit exceeded", nil
+	}
+	if quota != nil && quota.Remaining == 0 && !quota.Reset.IsZero() {
+		wait := untilReset(quota.Reset)
+		reason := fmt.Sprintf("Rate limit of %d requests exceeded", quota.Limit)
+		return wait, reason, nil
+	}
+
+	// a 403 is a secondary rate limit only if its message says so, otherwise
+	// it is a permission error that must be returned as is
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if !strings.Contains(strings.ToLower(string(body)), "secondary rate limit") {
+		return -1, "", nil
+	}
+	return secondaryRateLimitWait << attempt, "Secondary rate limit exceeded", nil
+}
+
+func recordQuota(req *http.Request, resp *http.Response) *Quota {
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return nil
+	}
+	remaining, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+
+	quotasMu.Lock()
+	defer quotasMu.Unlock()
+	key := fmt.Sprintf("%s/%s", req.URL.Host, resource)
+	quota, ok := quotas[key]
+	if !ok {
+		quota = &Quota{
+			Host:     req.URL.Host,
+			Resource: resource,
+		}
+		quotas[key] = quota
+	}
+	quota.Limit = limit
+	quota.Remaining = remaining
+	quota.Reset = time.Unix(reset, 0)
+	quota.Requests++
+	copied := *quota
+	return &copied
+}
+
+// QuotaUsage returns the remaining API quota of every Github host pinny has
+// sent requests to, sorted by host.
+func QuotaUsage() []*Quota {
+	quotasMu.Lock()
+	defer quotasMu.Unlock()
+
+	usage := []*Quota{}
+	for _, quota := range quotas {
+		copied := *quota
+		usage = append(usage, &copied)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Host != usage[j].Host {
+			return usage[i].Host < usage[j].Host
+		}
+		return usage[i].Resource < usage[j].Resource
+	})
+	return usage
+}
@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+// FixRenamed rewrites the uses: references of actions whose repo was renamed
+// or transferred to the name the repo lives under now.
+var FixRenamed = false
+
+// The repo path of an API request, /repos/owner/repo/ or, for the redirects
+// Github answers renamed and transferred repos with, /repositories/id/
+var apiRepoPathRegex = regexp.MustCompile(`/(?:repos/(?P<name>[^/]+/[^/]+)|repositories/(?P<id>\d+))(/|$)`)
+
+// repoRedirects maps owner/repo in lower case to the repo path requests for
+// it were redirected to
+var repoRedirects sync.Map
+
+var canonicalNameCache utils.Memo[string]
+
+var ownerExistsCache utils.Memo[bool]
+
+// MissingOwnerError is returned for actions whose owner does not exist. The
+// name is free, so anyone can register it and publish an action under the
+// same owner/repo.
+type MissingOwnerError struct {
+	Owner string
+	Repo  string
+}
+
+func (e *MissingOwnerError) Error() string {
+	return fmt.Sprintf("owner %s of %s/%s does not exist, anyone can register it and publish the action (repojacking)", e.Owner, e.Owner, e.Repo)
+}
+
+// recordRedirect remembers where a request for owner/repo was redirected to.
+// The http client follows redirects, the final request of resp tells where
+// the repo lives now.
+func recordRedirect(owner string, repo string, resp *github.Response) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	ok, matches := utils.MatchNamedRegex(apiRepoPathRegex, resp.Request.URL.Path)
+	if !ok || strings.EqualFold(matches["name"], fmt.Sprintf("%s/%s", owner, repo)) {
+		return
+	}
+	repoRedirects.Store(strings.ToLower(fmt.Sprintf("%s/%s", owner, repo)), matches)
+}
+
+// canonicalName returns the owner/repo that owner/repo was renamed or
+// transferred to, or an empty string if requests for it were not
+// redirected.
+func canonicalName(ctx context.Context, client *github.Client, owner string, repo string) (string, error) {
+	redirect, ok := repoRedirects.Load(strings.ToLower(fmt.Sprintf("%s/%s", owner, repo)))
+	if !ok {
+		return "", nil
+	}
+	matches := redirect.(map[string]string)
+	if matches["name"] != "" {
+		return matches["name"], nil
+	}
+	return canonicalNameCache.Do(matches["id"], func() (string, error) {
+		id, _ := strconv.ParseInt(matches["id"], 10, 64)
+		repository, _, err := rateLimited(ctx, func() (*github.Repository, *github.Response, error) {
+			return client.Repositories.GetByID(ctx, id)
+		})
+		if err != nil {
+			return "", err
+		}
+		return repository.GetFullName(), nil
+	})
+}
+
+// ownerExists reports whether the user or org owner exists.
+func ownerExists(ctx context.Context, client *github.Client, owner string) (bool, error) {
+	return ownerExistsCache.Do(strings.ToLower(owner), func() (bool, error) {
+		_, resp, err := rateLimited(ctx, func() (*github.User, *github.Response, error) {
+			return client.Users.Get(ctx, owner)
+		})
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+// missingRepoError turns the error of a lookup in owner/repo into a
+// MissingOwnerError if the repo was not found because its owner is gone.
+func missingRepoError(ctx context.Context, client *github.Client, owner string, repo string, err error) error {
+	var errorResponse *github.ErrorResponse
+	if !errors.As(err, &errorResponse) || errorResponse.Response.StatusCode != http.StatusNotFound {
+		return err
+	}
+	exists, ownerErr := ownerExists(ctx, client, owner)
+	if ownerErr != nil || exists {
+		return err
+	}
+	return &MissingOwnerError{
+		Owner: owner,
+		Repo:  repo,
+	}
+}
+
+// canonicalRef returns githubActionRef under the name its repo lives under
+// now.
+func (g *GithubActionRef) canonicalRef() *GithubActionRef {
+	canonical := *g
+	canonical.Owner, canonical.Repo, _ = strings.Cut(g.Canonical, "/")
+	return &canonical
+}
+
+func renamedWarning(githubActionRef *GithubActionRef) string {
+	return fmt.Sprintf("%s/%s has moved to %s, its old name can be registered by anyone to take over the action (repojacking)", githubActionRef.Owner, githubActionRef.Repo, githubActionRef.Canonical)
+}
@@ -0,0 +1,156 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+const (
+	// Github verified the signature against a key of the signer's account
+	SignatureVerified = "verified"
+	// The object is signed, but Github could not verify the signature
+	SignatureUnverified = "unverified"
+	// The object is not signed at all
+	SignatureUnsigned = "unsigned"
+)
+
+// commitCache maps owner/repo/sha to the commit
+var commitCache utils.Memo[*github.Commit]
+
+// signsReleasesCache maps owner/repo@ref to whether the releases of the
+// repo other than ref are signed
+var signsReleasesCache utils.Memo[bool]
+
+// signedReleases is how many of the newest releases signsReleases looks at
+const signedReleases = 3
+
+// Signature is the signature status of the object an action ref resolved
+// to: the tag object of annotated tags, the commit otherwise. Signer is the
+// tagger or committer, whose account Github checks the key against, and
+// Reason is why Github did not verify the signature.
+type Signature struct {
+	Status string `json:"status"`
+	Object string `json:"object"`
+	Signer string `json:"signer,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (s *Signature) String() string {
+	switch s.Status {
+	case SignatureVerified:
+		return fmt.Sprintf("verified %s signature of %s", s.Object, s.Signer)
+	case SignatureUnverified:
+		return fmt.Sprintf("unverified %s signature of %s: %s", s.Object, s.Signer, s.Reason)
+	default:
+		return fmt.Sprintf("unsigned %s", s.Object)
+	}
+}
+
+// Verified reports whether Github verified the signature.
+func (s *Signature) Verified() bool {
+	return s != nil && s.Status == SignatureVerified
+}
+
+func newSignature(object string, verification *github.SignatureVerification, signer *github.CommitAuthor) *Signature {
+	signature := &Signature{
+		Status: SignatureUnsigned,
+		Object: object,
+	}
+	if verification.GetSignature() == "" {
+		return signature
+	}
+	if signer != nil {
+		signature.Signer = fmt.Sprintf("%s <%s>", signer.GetName(), signer.GetEmail())
+	}
+	if verification.GetVerified() {
+		signature.Status = SignatureVerified
+	} else {
+		signature.Status = SignatureUnverified
+		signature.Reason = verification.GetReason()
+	}
+	return signature
+}
+
+func getCommit(ctx context.Context, client *github.Client, owner string, repo string, sha string) (*github.Commit, error) {
+	cacheKey := fmt.Sprintf("%s/%s/%s", owner, repo, sha)
+	return commitCache.Do(cacheKey, func() (*github.Commit, error) {
+		commit, _, err := rateLimited(ctx, func() (*github.Commit, *github.Response, error) {
+			return client.Git.GetCommit(ctx, owner, repo, sha)
+		})
+		return commit, err
+	})
+}
+
+// commitSignature returns the signature of commit sha.
+func commitSignature(ctx context.Context, client *github.Client, owner string, repo string, sha string) (*Signature, error) {
+	commit, err := getCommit(ctx, client, owner, repo, sha)
+	if err != nil {
+		return nil, err
+	}
+	return newSignature("commit", commit.GetVerification(), commit.GetCommitter()), nil
+}
+
+// refSignature returns the signature of the tag object of an annotated tag,
+// or of commit digest the ref points to otherwise.
+func refSignature(ctx context.Context, client *github.Client, owner string, repo string, r *github.Reference, digest string) (*Signature, error) {
+	if r.GetObject().GetType() == "tag" {
+		tag, err := getTag(ctx, client, owner, repo, r.GetObject().GetSHA())
+		if err != nil {
+			return nil, err
+		}
+		return newSignature("tag", tag.GetVerification(), tag.GetTagger()), nil
+	}
+	return commitSignature(ctx, client, owner, repo, digest)
+}
+
+// signsReleases reports whether the newest releases of the repo of
+// githubActionRef, other than its ref, all carry a verified signature, i.e.
+// whether the owner normally signs releases. Releases are tags of full
+// versions like v4.1.1, the major version tags moved along with them are
+// rarely signed. Lookups that fail count as unsigned.
+func signsReleases(githubActionRef *GithubActionRef) bool {
+	owner := githubActionRef.Owner
+	repo := githubActionRef.Repo
+	cacheKey := strings.ToLower(fmt.Sprintf("%s/%s@%s", owner, repo, githubActionRef.Ref))
+	signed, _ := signsReleasesCache.Do(cacheKey, func() (bool, error) {
+		ctx := context.Background()
+		client, err := getGithubClient(owner)
+		if err != nil {
+			return false, nil
+		}
+		tags, err := listMatchingRefs(ctx, client, owner, repo, "tags")
+		if err != nil {
+			return false, nil
+		}
+		releases := []*github.Reference{}
+		versions := map[*github.Reference]*utils.Semver{}
+		for _, tag := range tags {
+			name := strings.TrimPrefix(tag.GetRef(), "refs/tags/")
+			version, ok := utils.ParseSemver(name)
+			if !ok || version.Precision < 3 || version.Prerelease != "" || name == githubActionRef.Ref {
+				continue
+			}
+			releases = append(releases, tag)
+			versions[tag] = version
+		}
+		if len(releases) == 0 {
+			return false, nil
+		}
+		sort.Slice(releases, func(i, j int) bool {
+			return versions[releases[i]].Compare(versions[releases[j]]) > 0
+		})
+		for _, release := range releases[:min(len(releases), signedReleases)] {
+			signature, err := refSignature(ctx, client, owner, repo, release, release.GetObject().GetSHA())
+			if err != nil || !signature.Verified() {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	return signed
+}
@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/docker"
+	"github.com/koalalab-inc/pinny/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// UnpinWorkflow reverts PinWorkflow: actions and images pinned to a digest
+// are rewritten to the ref or tag named in the trailing comment pinny wrote
+// next to them, and the comment is dropped. References without such a
+// comment are left pinned. The result is written to <file>.tmp. The Github
+// API is never called.
+func UnpinWorkflow(file *UsesFile) error {
+	if file.Dockerfile {
+		return unpinDockerfile(file)
+	}
+
+	content, usesNodes, err := file.read()
+	if err != nil {
+		return err
+	}
+
+	edits := []yamlEdit{}
+	for _, node := range usesNodes {
+		actionString := node.Value
+		if strings.HasPrefix(actionString, "docker://") {
+			if imageString, ok := unpinnedImage(content, node); ok {
+				if !strings.HasPrefix(imageString, "docker://") {
+					imageString = fmt.Sprintf("docker://%s", imageString)
+				}
+				edits = append(edits, yamlEdit{
+					node:        node,
+					value:       imageString,
+					dropComment: true,
+				})
+			}
+		} else if strings.Contains(actionString, "@") {
+			githubActionRef, err := parseActionString(actionString)
+			if err != nil || !isFullSHA(githubActionRef.Ref) {
+				continue
+			}
+			comment, ok := parsePinComment(lineComment(content, node))
+			if !ok {
+				utils.Warnf("%s:%d: %s has no pinny comment naming its ref, leaving it pinned\n", file.Path, node.Line, actionString)
+				continue
+			}
+			unpinned := comment.actionRef(githubActionRef)
+			edits = append(edits, yamlEdit{
+				node:        node,
+				value:       unpinnedActionString(unpinned, githubActionRef),
+				dropComment: true,
+			})
+		}
+	}
+
+	imageNodes, err := file.images(content)
+	if err != nil {
+		return err
+	}
+	for _, node := range imageNodes {
+		if imageString, ok := unpinnedImage(content, node); ok {
+			edits = append(edits, yamlEdit{
+				node:        node,
+				value:       imageString,
+				dropComment: true,
+			})
+		}
+	}
+
+	unpinnedContent, err := applyYAMLEdits(content, edits)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Path, err)
+	}
+
+	return os.WriteFile(fmt.Sprintf("%s.tmp", file.Path), unpinnedContent, 0644)
+}
+
+// unpinnedActionString returns the action the legacy comment of pinned names
+// if it is the same action, or pinned with the ref of the comment otherwise,
+// e.g. when the action was rewritten to a new name with --fix-renamed.
+func unpinnedActionString(unpinned *GithubActionRef, pinned *GithubActionRef) string {
+	named, err := parseActionString(unpinned.Raw)
+	if err == nil &&
+		strings.EqualFold(named.Owner, pinned.Owner) &&
+		strings.EqualFold(named.Repo, pinned.Repo) &&
+		strings.EqualFold(named.Path, pinned.Path) {
+		return unpinned.Raw
+	}
+	return unpinned.NameWithRef()
+}
+
+// unpinnedImage returns the image named in the trailing comment of an image
+// pinned to a digest, if the comment names the same image without one.
+func unpinnedImage(content []byte, node *yaml.Node) (string, bool) {
+	pinned, err := docker.ParseImageRef(node.Value)
+	if err != nil || pinned.Digest == "" {
+		return "", false
+	}
+	comment := lineComment(content, node)
+	if comment == "" {
+		return "", false
+	}
+	imageRef, err := docker.ParseImageRef(comment)
+	if err != nil || imageRef.Digest != "" || imageRef.OriginalName("") != pinned.OriginalName("") {
+		return "", false
+	}
+	return comment, true
+}
+
+// unpinDockerfile unpins the base images of the Dockerfile of a docker
+// container action the way pinny docker unpin does, and moves the result to
+// <file>.tmp.
+func unpinDockerfile(file *UsesFile) error {
+	unpinnedFile := fmt.Sprintf("%s.unpinned.tmp", file.Path)
+	err := docker.GenerateUnpinnedDockerfile(file.Path)
+	if err != nil {
+		os.Remove(unpinnedFile)
+		return err
+	}
+	return os.Rename(unpinnedFile, fmt.Sprintf("%s.tmp", file.Path))
+}
@@ -0,0 +1,154 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+const (
+	UpdatePatch = "patch"
+	UpdateMinor = "minor"
+	UpdateMajor = "major"
+)
+
+// Bump describes a pinned action that was moved to a newer release.
+type Bump struct {
+	File       string
+	Line       int
+	Action     string
+	From       string
+	To         string
+	FromDigest string
+	ToDigest   string
+}
+
+func validUpdatePolicy(policy string) bool {
+	return policy == UpdatePatch || policy == UpdateMinor || policy == UpdateMajor
+}
+
+// UpdateWorkflow bumps the SHA pinned actions of a workflow or action
+// manifest to the newest release allowed by policy. The release a pin
+// currently tracks is read from the trailing comment pinny wrote when
+// pinning it. Like PinWorkflow, the result is written to <file>.tmp.
+func UpdateWorkflow(usesFile *UsesFile, policy string) ([]*Bump, error) {
+	if !validUpdatePolicy(policy) {
+		return nil, fmt.Errorf("invalid update policy %q, expected one of %s, %s or %s", policy, UpdatePatch, UpdateMinor, UpdateMajor)
+	}
+
+	file := usesFile.Path
+	content, usesNodes, err := usesFile.read()
+	if err != nil {
+		return nil, err
+	}
+
+	bumps := []*Bump{}
+	edits := []yamlEdit{}
+	for _, node := range usesNodes {
+		actionString := node.Value
+		if strings.HasPrefix(actionString, "docker://") || !strings.Contains(actionString, "@") {
+			continue
+		}
+		githubActionRef, err := parseActionString(actionString)
+		if err != nil || !isFullSHA(githubActionRef.Ref) {
+			continue
+		}
+		comment, ok := parsePinComment(lineComment(content, node))
+		if !ok {
+			continue
+		}
+		current, ok := comment.version()
+		if !ok {
+			continue
+		}
+
+		newer, err := newerTag(githubActionRef.Owner, githubActionRef.Repo, current, policy)
+		if err != nil {
+			return nil, err
+		}
+		if newer == nil {
+			continue
+		}
+
+		name := strings.TrimSuffix(actionString, fmt.Sprintf("@%s", githubActionRef.Ref))
+		updatedActionRef, err := GetGithubActionRefWithDigest(fmt.Sprintf("%s@%s", name, newer.Raw))
+		if err != nil {
+			return nil, err
+		}
+		if updatedActionRef.Digest == githubActionRef.Ref {
+			continue
+		}
+
+		edits = append(edits, yamlEdit{
+			node:    node,
+			value:   updatedActionRef.NameWithDigest(),
+			comment: formatPinComment(updatedActionRef),
+		})
+		bumps = append(bumps, &Bump{
+			File:       file,
+			Line:       node.Line,
+			Action:     name,
+			From:       current.Raw,
+			To:         newer.Raw,
+			FromDigest: githubActionRef.Ref,
+			ToDigest:   updatedActionRef.Digest,
+		})
+	}
+
+	updatedContent, err := applyYAMLEdits(content, edits)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	err = os.WriteFile(fmt.Sprintf("%s.tmp", file), updatedContent, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return bumps, nil
+}
+
+// newerTag returns the newest release tag of owner/repo that is newer than
+// current and allowed by policy, or nil if there is none. Only tags written
+// the same way as current (same prefix and precision) are considered, so a
+// pin that follows v3 moves to v4 rather than to v4.1.2.
+func newerTag(owner string, repo string, current *utils.Semver, policy string) (*utils.Semver, error) {
+	client, err := getGithubClient(owner)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := listRepoRefs(context.Background(), client, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var newest *utils.Semver
+	for _, ref := range refs {
+		tagName, ok := strings.CutPrefix(ref.GetRef(), "refs/tags/")
+		if !ok {
+			continue
+		}
+		version, ok := utils.ParseSemver(tagName)
+		if !ok || version.Prerelease != "" {
+			continue
+		}
+		if version.Prefix != current.Prefix || version.Precision != current.Precision {
+			continue
+		}
+		if policy == UpdatePatch && (version.Major != current.Major || version.Minor != current.Minor) {
+			continue
+		}
+		if policy == UpdateMinor && version.Major != current.Major {
+			continue
+		}
+		if version.Compare(current) <= 0 {
+			continue
+		}
+		if newest == nil || version.Compare(newest) > 0 {
+			newest = version
+		}
+	}
+	return newest, nil
+}
@@ -0,0 +1,397 @@
+package actions
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlEdit replaces the scalar held by node with value and, when comment is
+// not empty, replaces the trailing comment on the scalar's line with it.
+// dropComment removes the trailing comment instead.
+type yamlEdit struct {
+	node        *yaml.Node
+	value       string
+	comment     string
+	dropComment bool
+}
+
+func parseYAMLDocuments(content []byte) ([]*yaml.Node, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	docs := []*yaml.Node{}
+	for {
+		doc := &yaml.Node{}
+		err := decoder.Decode(doc)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		return doc.Content[0]
+	}
+	return doc
+}
+
+func isScalar(node *yaml.Node) bool {
+	return node != nil && node.Kind == yaml.ScalarNode
+}
+
+// findWorkflowUsesNodes returns the jobs.*.uses and jobs.*.steps[*].uses
+// scalars of a workflow, in the order they appear in the file.
+func findWorkflowUsesNodes(content []byte) ([]*yaml.Node, error) {
+	docs, err := parseYAMLDocuments(content)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*yaml.Node{}
+	for _, doc := range docs {
+		jobs := mappingValue(documentRoot(doc), "jobs")
+		if jobs == nil || jobs.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 1; i < len(jobs.Content); i += 2 {
+			job := jobs.Content[i]
+			if uses := mappingValue(job, "uses"); isScalar(uses) {
+				nodes = append(nodes, uses)
+			}
+			steps := mappingValue(job, "steps")
+			if steps == nil || steps.Kind != yaml.SequenceNode {
+				continue
+			}
+			for _, step := range steps.Content {
+				if uses := mappingValue(step, "uses"); isScalar(uses) {
+					nodes = append(nodes, uses)
+				}
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// findWorkflowImageNodes returns the jobs.*.container, jobs.*.container.image
+// and jobs.*.services.*.image scalars of a workflow, in the order they appear
+// in the file. Images chosen through expressions can't be pinned and are
+// left out.
+func findWorkflowImageNodes(content []byte) ([]*yaml.Node, error) {
+	docs, err := parseYAMLDocuments(content)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*yaml.Node{}
+	add := func(image *yaml.Node) {
+		if isScalar(image) && image.Value != "" && !strings.Contains(image.Value, "${{") {
+			nodes = append(nodes, image)
+		}
+	}
+	for _, doc := range docs {
+		jobs := mappingValue(documentRoot(doc), "jobs")
+		if jobs == nil || jobs.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 1; i < len(jobs.Content); i += 2 {
+			job := jobs.Content[i]
+			container := mappingValue(job, "container")
+			if isScalar(container) {
+				add(container)
+			} else {
+				add(mappingValue(container, "image"))
+			}
+			services := mappingValue(job, "services")
+			if services == nil || services.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 1; j < len(services.Content); j += 2 {
+				add(mappingValue(services.Content[j], "image"))
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// findActionUsesNodes returns the runs.steps[*].uses scalars of a composite
+// action manifest and the runs.image scalar of a docker container action.
+func findActionUsesNodes(content []byte) ([]*yaml.Node, error) {
+	docs, err := parseYAMLDocuments(content)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*yaml.Node{}
+	for _, doc := range docs {
+		runs := mappingValue(documentRoot(doc), "runs")
+		if image := mappingValue(runs, "image"); isScalar(image) && strings.HasPrefix(image.Value, "docker://") {
+			nodes = append(nodes, image)
+		}
+		steps := mappingValue(runs, "steps")
+		if steps == nil || steps.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, step := range steps.Content {
+			if uses := mappingValue(step, "uses"); isScalar(uses) {
+				nodes = append(nodes, uses)
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// findActionRuntime returns the runs.using of an action manifest, like
+// node20, composite or docker.
+func findActionRuntime(content []byte) (string, error) {
+	docs, err := parseYAMLDocuments(content)
+	if err != nil {
+		return "", err
+	}
+	for _, doc := range docs {
+		using := mappingValue(mappingValue(documentRoot(doc), "runs"), "using")
+		if isScalar(using) {
+			return using.Value, nil
+		}
+	}
+	return "", nil
+}
+
+// findActionDockerfile returns the runs.image of a docker container action
+// that is built from a Dockerfile in the action's repository, relative to
+// the action manifest, or an empty string.
+func findActionDockerfile(content []byte) (string, error) {
+	docs, err := parseYAMLDocuments(content)
+	if err != nil {
+		return "", err
+	}
+	for _, doc := range docs {
+		image := mappingValue(mappingValue(documentRoot(doc), "runs"), "image")
+		if isScalar(image) && image.Value != "" && !strings.HasPrefix(image.Value, "docker://") {
+			return image.Value, nil
+		}
+	}
+	return "", nil
+}
+
+// applyYAMLEdits rewrites the scalars referenced by edits in place. Only the
+// scalar token and the trailing comment of an edited line are touched, every
+// other byte of content is preserved.
+func applyYAMLEdits(content []byte, edits []yamlEdit) ([]byte, error) {
+	if len(edits) == 0 {
+		return content, nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+
+	editsByLine := make(map[int][]yamlEdit)
+	commentsByLine := make(map[int][]string)
+	droppedComments := make(map[int]bool)
+	for _, edit := range edits {
+		line := edit.node.Line - 1
+		if edit.node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			// The value of a block scalar lives on the lines following its
+			// header, comments can only go on the header itself
+			line = blockScalarLine(lines, edit.node)
+			if line < 0 {
+				return nil, fmt.Errorf("unable to locate value %q on line %d", edit.node.Value, edit.node.Line)
+			}
+		}
+		if line < 0 || line >= len(lines) {
+			return nil, fmt.Errorf("line %d out of range", edit.node.Line)
+		}
+		editsByLine[line] = append(editsByLine[line], edit)
+	}
+
+	for lineNumber, lineEdits := range editsByLine {
+		line, eol := splitLineEnding(lines[lineNumber])
+		// Rewrite right to left so earlier offsets stay valid
+		sort.Slice(lineEdits, func(i, j int) bool {
+			return lineEdits[i].node.Column > lineEdits[j].node.Column
+		})
+		for _, edit := range lineEdits {
+			start, end, err := scalarBounds(line, edit.node)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", edit.node.Line, err)
+			}
+			line = line[:start] + quoteScalar(edit.value, edit.node.Style) + line[end:]
+			if edit.comment != "" {
+				commentLine := edit.node.Line - 1
+				commentsByLine[commentLine] = append([]string{edit.comment}, commentsByLine[commentLine]...)
+			} else if edit.dropComment {
+				droppedComments[edit.node.Line-1] = true
+			}
+		}
+		lines[lineNumber] = line + eol
+	}
+
+	for lineNumber, comments := range commentsByLine {
+		line, eol := splitLineEnding(lines[lineNumber])
+		line = fmt.Sprintf("%s # %s", strings.TrimRight(stripComment(line), " \t"), strings.Join(comments, "; "))
+		lines[lineNumber] = line + eol
+	}
+
+	for lineNumber := range droppedComments {
+		if _, ok := commentsByLine[lineNumber]; ok {
+			continue
+		}
+		line, eol := splitLineEnding(lines[lineNumber])
+		lines[lineNumber] = strings.TrimRight(stripComment(line), " \t") + eol
+	}
+
+	return []byte(strings.Join(lines, "")), nil
+}
+
+func splitLineEnding(line string) (string, string) {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2], "\r\n"
+	} else if strings.HasSuffix(line, "\n") {
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
+
+// runeOffset converts a 1-based rune column reported by the YAML parser into
+// a byte offset within line.
+func runeOffset(line string, column int) int {
+	offset := 0
+	for i := 1; i < column && offset < len(line); i++ {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// valueOffset returns the byte offset of the value of node within line. The
+// column the parser reports is that of the anchor or tag of the node if it
+// has any, like &x or !!str, so they are skipped.
+func valueOffset(line string, node *yaml.Node) int {
+	offset := runeOffset(line, node.Column)
+	for offset < len(line) && (line[offset] == '&' || line[offset] == '!') {
+		for offset < len(line) && line[offset] != ' ' && line[offset] != '\t' {
+			offset++
+		}
+		for offset < len(line) && (line[offset] == ' ' || line[offset] == '\t') {
+			offset++
+		}
+	}
+	return offset
+}
+
+func blockScalarLine(lines []string, node *yaml.Node) int {
+	value := strings.TrimSpace(node.Value)
+	for i := node.Line; i < len(lines); i++ {
+		line, _ := splitLineEnding(lines[i])
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if trimmed == value {
+			return i
+		}
+		break
+	}
+	return -1
+}
+
+// scalarBounds returns the byte range of node's token within line.
+func scalarBounds(line string, node *yaml.Node) (int, int, error) {
+	switch {
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		start := strings.Index(line, strings.TrimSpace(node.Value))
+		return start, start + len(strings.TrimSpace(node.Value)), nil
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		start := valueOffset(line, node)
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				return start, i + 1, nil
+			}
+		}
+		return 0, 0, fmt.Errorf("unterminated double quoted value %q", node.Value)
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		start := valueOffset(line, node)
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return start, i + 1, nil
+			}
+		}
+		return 0, 0, fmt.Errorf("unterminated single quoted value %q", node.Value)
+	default:
+		start := valueOffset(line, node)
+		end := start + len(node.Value)
+		if end > len(line) || line[start:end] != node.Value {
+			return 0, 0, fmt.Errorf("multi-line value %q is not supported", node.Value)
+		}
+		return start, end, nil
+	}
+}
+
+func quoteScalar(value string, style yaml.Style) string {
+	if style&yaml.DoubleQuotedStyle != 0 {
+		return fmt.Sprintf("\"%s\"", value)
+	} else if style&yaml.SingleQuotedStyle != 0 {
+		return fmt.Sprintf("'%s'", value)
+	}
+	return value
+}
+
+// stripComment drops a trailing YAML comment from line. A comment starts at
+// a '#' that is outside quotes and preceded by whitespace.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '\'' && c == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			// '' is a quote within a single quoted value
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t{[,:", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// lineComment returns the trailing comment on the line holding node, without
+// the leading '#'.
+func lineComment(content []byte, node *yaml.Node) string {
+	lines := strings.SplitAfter(string(content), "\n")
+	lineNumber := node.Line - 1
+	if lineNumber < 0 || lineNumber >= len(lines) {
+		return ""
+	}
+	line, _ := splitLineEnding(lines[lineNumber])
+	comment := strings.TrimPrefix(line, stripComment(line))
+	return strings.TrimSpace(strings.TrimPrefix(comment, "#"))
+}
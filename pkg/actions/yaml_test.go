@@ -0,0 +1,138 @@
+package actions
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSHA = "b4ffde65f46336ab88eb53be808477a3936bae11"
+
+// pinEdits pins every actions/checkout@v4 of a workflow to testSHA.
+func pinEdits(t *testing.T, content string, comment string, dropComment bool) []yamlEdit {
+	t.Helper()
+	nodes, err := findWorkflowUsesNodes([]byte(content))
+	if err != nil {
+		t.Fatalf("findWorkflowUsesNodes() error = %v", err)
+	}
+	edits := []yamlEdit{}
+	for _, node := range nodes {
+		edits = append(edits, yamlEdit{
+			node:        node,
+			value:       strings.Replace(node.Value, "@v4", "@"+testSHA, 1),
+			comment:     comment,
+			dropComment: dropComment,
+		})
+	}
+	return edits
+}
+
+func TestApplyYAMLEdits(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		comment     string
+		dropComment bool
+		want        string
+	}{
+		{
+			name:    "plain",
+			content: "jobs:\n  a:\n    steps:\n      - uses: actions/checkout@v4\n",
+			comment: "v4.1.1 | v4",
+			want:    "jobs:\n  a:\n    steps:\n      - uses: actions/checkout@" + testSHA + " # v4.1.1 | v4\n",
+		},
+		{
+			name:    "comment replaced",
+			content: "jobs:\n  a:\n    steps:\n      - uses: actions/checkout@v4   # checkout the repo\n",
+			comment: "v4.1.1 | v4",
+			want:    "jobs:\n  a:\n    steps:\n      - uses: actions/checkout@" + testSHA + " # v4.1.1 | v4\n",
+		},
+		{
+			name:        "comment dropped",
+			content:     "jobs:\n  a:\n    steps:\n      - uses: actions/checkout@v4 # v4.1.1 | v4\n",
+			dropComment: true,
+			want:        "jobs:\n  a:\n    steps:\n      - uses: actions/checkout@" + testSHA + "\n",
+		},
+		{
+			name:    "double quoted",
+			content: "jobs:\n  a:\n    steps:\n      - uses: \"actions/checkout@v4\" # \"quoted\"\n",
+			comment: "v4.1.1",
+			want:    "jobs:\n  a:\n    steps:\n      - uses: \"actions/checkout@" + testSHA + "\" # v4.1.1\n",
+		},
+		{
+			name:    "single quoted",
+			content: "jobs:\n  a:\n    steps:\n      - uses: 'actions/checkout@v4'\n",
+			comment: "v4.1.1",
+			want:    "jobs:\n  a:\n    steps:\n      - uses: 'actions/checkout@" + testSHA + "' # v4.1.1\n",
+		},
+		{
+			name:    "flow style",
+			content: "jobs:\n  a:\n    steps:\n      - {name: checkout, uses: actions/checkout@v4, with: {fetch-depth: 0}}\n",
+			comment: "v4.1.1",
+			want:    "jobs:\n  a:\n    steps:\n      - {name: checkout, uses: actions/checkout@" + testSHA + ", with: {fetch-depth: 0}} # v4.1.1\n",
+		},
+		{
+			name:    "flow style on one line",
+			content: "jobs: {a: {steps: [{uses: actions/checkout@v4}, {uses: actions/setup-go@v4}]}}\n",
+			comment: "v4.1.1",
+			want:    "jobs: {a: {steps: [{uses: actions/checkout@" + testSHA + "}, {uses: actions/setup-go@" + testSHA + "}]}} # v4.1.1; v4.1.1\n",
+		},
+		{
+			name:    "anchor and tag",
+			content: "jobs:\n  a:\n    steps:\n      - uses: &checkout actions/checkout@v4\n      - uses: !!str  actions/setup-go@v4\n",
+			comment: "v4.1.1",
+			want:    "jobs:\n  a:\n    steps:\n      - uses: &checkout actions/checkout@" + testSHA + " # v4.1.1\n      - uses: !!str  actions/setup-go@" + testSHA + " # v4.1.1\n",
+		},
+		{
+			name:    "block scalar",
+			content: "jobs:\n  a:\n    steps:\n      - uses: >-\n          actions/checkout@v4\n",
+			comment: "v4.1.1",
+			want:    "jobs:\n  a:\n    steps:\n      - uses: >- # v4.1.1\n          actions/checkout@" + testSHA + "\n",
+		},
+		{
+			name:    "line endings and other lines kept",
+			content: "# build\r\njobs:\r\n  a:\r\n    steps:\r\n      - uses: actions/checkout@v4\r\n      - run: echo '#'  # not touched\r\n",
+			comment: "v4.1.1",
+			want:    "# build\r\njobs:\r\n  a:\r\n    steps:\r\n      - uses: actions/checkout@" + testSHA + " # v4.1.1\r\n      - run: echo '#'  # not touched\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := pinEdits(t, tt.content, tt.comment, tt.dropComment)
+			got, err := applyYAMLEdits([]byte(tt.content), edits)
+			if err != nil {
+				t.Fatalf("applyYAMLEdits() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("applyYAMLEdits() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineComment(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"uses: actions/checkout@v4 # v4.1.1 | v4", "v4.1.1 | v4"},
+		{"uses: actions/checkout@v4#not-a-comment", ""},
+		{"uses: \"actions/checkout@v4 # quoted\"", ""},
+		{"uses: 'it''s # quoted' # comment", "comment"},
+		{"uses: actions/checkout@v4", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			content := "steps:\n  - " + tt.line + "\n"
+			nodes, err := findActionUsesNodes([]byte("runs:\n  " + content))
+			if err != nil {
+				t.Fatalf("findActionUsesNodes() error = %v", err)
+			}
+			if len(nodes) != 1 {
+				t.Fatalf("found %d uses nodes, want 1", len(nodes))
+			}
+			if got := lineComment([]byte("runs:\n  "+content), nodes[0]); got != tt.want {
+				t.Errorf("lineComment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
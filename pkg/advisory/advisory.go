@@ -0,0 +1,441 @@
+package advisory
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+// compromisedJSON is the list of commits of actions known to be compromised
+// that pinny ships with. Compromised commits are often reachable from tags
+// of the upstream repo, so they pass every other check.
+//
+//go:embed compromised.json
+var compromisedJSON []byte
+
+// Ecosystem names of Github Actions in the OSV files of the Github Advisory
+// Database and in the responses of the Github advisories API
+const (
+	osvEcosystem = "GitHub Actions"
+	apiEcosystem = "actions"
+)
+
+// constraint is a single comparison like >= 1.0.0 a version has to satisfy.
+type constraint struct {
+	op      string
+	version *utils.Semver
+}
+
+func (c *constraint) satisfiedBy(version *utils.Semver) bool {
+	cmp := compareVersions(version, c.version)
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp == 0
+	}
+}
+
+// compareVersions compares versions numerically, so the v3 tag of an action
+// is the same as the 3.0.0 named in an advisory.
+func compareVersions(a *utils.Semver, b *utils.Semver) int {
+	numeric := func(s *utils.Semver) *utils.Semver {
+		return &utils.Semver{Major: s.Major, Minor: s.Minor, Patch: s.Patch, Prerelease: s.Prerelease}
+	}
+	return numeric(a).Compare(numeric(b))
+}
+
+// versionRange is a range of affected versions along with the version the
+// range is fixed in, if one is known.
+type versionRange struct {
+	constraints []*constraint
+	fixed       string
+}
+
+// affected is an action affected by an advisory. A version is affected if
+// it satisfies every constraint of any of the ranges, or is listed in
+// versions. Commits listed in shas are affected whatever their version, and
+// are fixed in fixed.
+type affected struct {
+	action   string
+	ranges   []*versionRange
+	versions []*utils.Semver
+	shas     map[string]bool
+	fixed    string
+}
+
+// affects reports whether the commit sha or the release version is
+// affected, and the version the affected range is fixed in.
+func (a *affected) affects(sha string, version *utils.Semver) (bool, string) {
+	if a.shas[strings.ToLower(sha)] {
+		return true, a.fixed
+	}
+	if version == nil {
+		return false, ""
+	}
+	for _, r := range a.ranges {
+		inRange := true
+		for _, c := range r.constraints {
+			inRange = inRange && c.satisfiedBy(version)
+		}
+		if inRange {
+			return true, r.fixed
+		}
+	}
+	for _, listed := range a.versions {
+		if compareVersions(version, listed) == 0 {
+			return true, ""
+		}
+	}
+	return false, ""
+}
+
+type Advisory struct {
+	ID       string
+	Summary  string
+	affected []*affected
+}
+
+// Match is an advisory that affects a pinned action. Fixed is the first
+// version the advisory is fixed in, if it names one.
+type Match struct {
+	ID      string `json:"id"`
+	Summary string `json:"summary"`
+	Fixed   string `json:"fixed,omitempty"`
+}
+
+func (m *Match) String() string {
+	if m.Fixed == "" {
+		return fmt.Sprintf("%s: %s", m.ID, m.Summary)
+	}
+	return fmt.Sprintf("%s: %s, upgrade to %s", m.ID, m.Summary, m.Fixed)
+}
+
+type Database struct {
+	Advisories []*Advisory
+}
+
+// Load reads the advisories pinny ships with and the advisories in paths.
+// A path is a JSON file or a directory searched for JSON files, like a
+// clone of the Github Advisory Database. Files hold OSV advisories, the
+// responses of the Github advisories API or lists of compromised commits
+// in the format of compromised.json, as a single object or an array.
+// Withdrawn advisories are left out.
+func Load(paths []string) (*Database, error) {
+	db := &Database{}
+	err := db.parse("compromised.json", compromisedJSON)
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (path != root && !strings.HasSuffix(d.Name(), ".json")) {
+				return nil
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return db.parse(path, content)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func (db *Database) parse(file string, content []byte) error {
+	content = bytes.TrimSpace(content)
+	if bytes.HasPrefix(content, []byte("[")) {
+		documents := []json.RawMessage{}
+		err := json.Unmarshal(content, &documents)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for _, document := range documents {
+			err := db.parse(file, document)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	keys := map[string]json.RawMessage{}
+	err := json.Unmarshal(content, &keys)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	switch {
+	case keys["compromised"] != nil:
+		err = db.parseCompromised(content)
+	case keys["ghsa_id"] != nil:
+		err = db.parseAPIAdvisory(content)
+	case keys["affected"] != nil:
+		err = db.parseOSVAdvisory(content)
+	default:
+		return fmt.Errorf("%s: unknown advisory format", file)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+func (db *Database) add(advisory *Advisory) {
+	if len(advisory.affected) > 0 {
+		db.Advisories = append(db.Advisories, advisory)
+	}
+}
+
+type compromisedList struct {
+	Compromised []struct {
+		ID      string   `json:"id"`
+		Action  string   `json:"action"`
+		Summary string   `json:"summary"`
+		SHAs    []string `json:"shas"`
+		Fixed   string   `json:"fixed"`
+	} `json:"compromised"`
+}
+
+func (db *Database) parseCompromised(content []byte) error {
+	list := &compromisedList{}
+	err := json.Unmarshal(content, list)
+	if err != nil {
+		return err
+	}
+	for _, entry := range list.Compromised {
+		shas := make(map[string]bool)
+		for _, sha := range entry.SHAs {
+			shas[strings.ToLower(sha)] = true
+		}
+		db.add(&Advisory{
+			ID:      entry.ID,
+			Summary: entry.Summary,
+			affected: []*affected{{
+				action: strings.ToLower(entry.Action),
+				shas:   shas,
+				fixed:  entry.Fixed,
+			}},
+		})
+	}
+	return nil
+}
+
+// osvAdvisory is an advisory of the Github Advisory Database, which is kept
+// in the OSV format.
+type osvAdvisory struct {
+	ID        string `json:"id"`
+	Summary   string `json:"summary"`
+	Withdrawn string `json:"withdrawn"`
+	Affected  []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string `json:"type"`
+			Events []struct {
+				Introduced   string `json:"introduced"`
+				Fixed        string `json:"fixed"`
+				LastAffected string `json:"last_affected"`
+			} `json:"events"`
+		} `json:"ranges"`
+		Versions []string `json:"versions"`
+	} `json:"affected"`
+}
+
+func (db *Database) parseOSVAdvisory(content []byte) error {
+	osv := &osvAdvisory{}
+	err := json.Unmarshal(content, osv)
+	if err != nil {
+		return err
+	}
+	// withdrawn advisories were published in error
+	if osv.Withdrawn != "" {
+		return nil
+	}
+	advisory := &Advisory{
+		ID:      osv.ID,
+		Summary: osv.Summary,
+	}
+	for _, pkg := range osv.Affected {
+		if pkg.Package.Ecosystem != osvEcosystem {
+			continue
+		}
+		a := &affected{
+			action: strings.ToLower(pkg.Package.Name),
+		}
+		for _, r := range pkg.Ranges {
+			if r.Type != "ECOSYSTEM" && r.Type != "SEMVER" {
+				continue
+			}
+			// events are ordered, every introduced version starts a range
+			// that the next fixed or last affected version ends
+			var current []*constraint
+			for _, event := range r.Events {
+				switch {
+				case event.Introduced != "":
+					if current != nil {
+						a.ranges = append(a.ranges, &versionRange{constraints: current})
+					}
+					current = []*constraint{}
+					if version, ok := utils.ParseSemver(event.Introduced); ok && event.Introduced != "0" {
+						current = append(current, &constraint{op: ">=", version: version})
+					}
+				case event.Fixed != "" && current != nil:
+					if version, ok := utils.ParseSemver(event.Fixed); ok {
+						a.ranges = append(a.ranges, &versionRange{
+							constraints: append(current, &constraint{op: "<", version: version}),
+							fixed:       event.Fixed,
+						})
+					}
+					current = nil
+				case event.LastAffected != "" && current != nil:
+					if version, ok := utils.ParseSemver(event.LastAffected); ok {
+						a.ranges = append(a.ranges, &versionRange{constraints: append(current, &constraint{op: "<=", version: version})})
+					}
+					current = nil
+				}
+			}
+			if current != nil {
+				a.ranges = append(a.ranges, &versionRange{constraints: current})
+			}
+		}
+		for _, v := range pkg.Versions {
+			if version, ok := utils.ParseSemver(v); ok {
+				a.versions = append(a.versions, version)
+			}
+		}
+		advisory.affected = append(advisory.affected, a)
+	}
+	db.add(advisory)
+	return nil
+}
+
+// apiAdvisory is an advisory as returned by the Github advisories API, e.g.
+// gh api /advisories?ecosystem=actions
+type apiAdvisory struct {
+	GHSAID          string `json:"ghsa_id"`
+	Summary         string `json:"summary"`
+	WithdrawnAt     string `json:"withdrawn_at"`
+	Vulnerabilities []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		VulnerableVersionRange string `json:"vulnerable_version_range"`
+		FirstPatchedVersion    string `json:"first_patched_version"`
+	} `json:"vulnerabilities"`
+}
+
+func (db *Database) parseAPIAdvisory(content []byte) error {
+	api := &apiAdvisory{}
+	err := json.Unmarshal(content, api)
+	if err != nil {
+		return err
+	}
+	if api.WithdrawnAt != "" {
+		return nil
+	}
+	advisory := &Advisory{
+		ID:      api.GHSAID,
+		Summary: api.Summary,
+	}
+	for _, vulnerability := range api.Vulnerabilities {
+		if vulnerability.Package.Ecosystem != apiEcosystem {
+			continue
+		}
+		constraints, err := parseVersionRange(vulnerability.VulnerableVersionRange)
+		if err != nil {
+			// one odd entry must not keep the rest of the database from
+			// being matched
+			utils.Warnf("Skipping %s of advisory %s: %s\n", vulnerability.Package.Name, api.GHSAID, err)
+			continue
+		}
+		advisory.affected = append(advisory.affected, &affected{
+			action: strings.ToLower(vulnerability.Package.Name),
+			ranges: []*versionRange{{
+				constraints: constraints,
+				fixed:       vulnerability.FirstPatchedVersion,
+			}},
+		})
+	}
+	db.add(advisory)
+	return nil
+}
+
+// parseVersionRange parses ranges like ">= 1.0.0, < 1.2.3" or "<= 45.0.7".
+func parseVersionRange(versionRange string) ([]*constraint, error) {
+	constraints := []*constraint{}
+	for _, part := range strings.Split(versionRange, ",") {
+		part = strings.TrimSpace(part)
+		op := "="
+		for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				break
+			}
+		}
+		v := strings.TrimSpace(strings.TrimPrefix(part, op))
+		version, ok := utils.ParseSemver(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid version range %q", versionRange)
+		}
+		constraints = append(constraints, &constraint{op: op, version: version})
+	}
+	return constraints, nil
+}
+
+// Match returns the advisories affecting owner/repo pinned to sha. version
+// is the release the pin tracks, like v3.5.3, or empty if it is unknown, in
+// which case only compromised commits are matched.
+func (db *Database) Match(action string, sha string, version string) []*Match {
+	action = strings.ToLower(action)
+	semver, ok := utils.ParseSemver(version)
+	if !ok {
+		semver = nil
+	}
+
+	matches := []*Match{}
+	for _, advisory := range db.Advisories {
+		for _, a := range advisory.affected {
+			if a.action != action {
+				continue
+			}
+			ok, fixed := a.affects(sha, semver)
+			if !ok {
+				continue
+			}
+			// suggest the fixed release the way the action's tags are
+			// written
+			if semver != nil && semver.Prefix != "" && fixed != "" && !strings.HasPrefix(fixed, semver.Prefix) {
+				fixed = semver.Prefix + fixed
+			}
+			matches = append(matches, &Match{
+				ID:      advisory.ID,
+				Summary: advisory.Summary,
+				Fixed:   fixed,
+			})
+			break
+		}
+	}
+	return matches
+}
@@ -0,0 +1,144 @@
+package advisory
+
+import (
+	"testing"
+
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+func mustParseSemver(t *testing.T, tag string) *utils.Semver {
+	t.Helper()
+	version, ok := utils.ParseSemver(tag)
+	if !ok {
+		t.Fatalf("ParseSemver(%q) failed", tag)
+	}
+	return version
+}
+
+func TestParseVersionRange(t *testing.T) {
+	tests := []struct {
+		versionRange string
+		version      string
+		want         bool
+	}{
+		{">= 1.0.0, < 1.2.3", "v1.0.0", true},
+		{">= 1.0.0, < 1.2.3", "v1", true},
+		{">= 1.0.0, < 1.2.3", "v1.2.2", true},
+		{">= 1.0.0, < 1.2.3", "v1.2.3", false},
+		{">= 1.0.0, < 1.2.3", "v0.9.9", false},
+		{">= 1.0.0, < 1.2.3", "v1.2.3-rc.1", true},
+		{"<= 45.0.7", "v45.0.7", true},
+		{"<= 45.0.7", "v45.0.8", false},
+		{"<= 45.0.7", "v46", false},
+		{"> 2.0.0", "v2.0.0", false},
+		{"> 2.0.0", "v2.0.1", true},
+		{"= 2.0.0", "2.0.0", true},
+		{"2.0.0", "v2", true},
+		{"2.0.0", "v2.0.1", false},
+		{"< 1.0.0-rc.10", "v1.0.0-rc.2", true},
+		{"< 1.0.0-rc.10", "v1.0.0-rc.11", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.versionRange+"_"+tt.version, func(t *testing.T) {
+			constraints, err := parseVersionRange(tt.versionRange)
+			if err != nil {
+				t.Fatalf("parseVersionRange(%q) error = %v", tt.versionRange, err)
+			}
+			a := &affected{ranges: []*versionRange{{constraints: constraints}}}
+			version := mustParseSemver(t, tt.version)
+			if got, _ := a.affects("", version); got != tt.want {
+				t.Errorf("%s in %q = %v, want %v", tt.version, tt.versionRange, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVersionRangeInvalid(t *testing.T) {
+	for _, versionRange := range []string{"", ">= latest", ">= 1.0.0, main"} {
+		if _, err := parseVersionRange(versionRange); err == nil {
+			t.Errorf("parseVersionRange(%q) error = nil, want an error", versionRange)
+		}
+	}
+}
+
+const testAdvisories = `[
+	{
+		"ghsa_id": "GHSA-api",
+		"summary": "api advisory",
+		"vulnerabilities": [{
+			"package": {"ecosystem": "actions", "name": "Some/Action"},
+			"vulnerable_version_range": ">= 2.0.0, < 2.3.1",
+			"first_patched_version": "2.3.1"
+		}]
+	},
+	{
+		"ghsa_id": "GHSA-withdrawn",
+		"summary": "withdrawn advisory",
+		"withdrawn_at": "2024-01-01T00:00:00Z",
+		"vulnerabilities": [{
+			"package": {"ecosystem": "actions", "name": "some/action"},
+			"vulnerable_version_range": ">= 0.0.1"
+		}]
+	},
+	{
+		"id": "GHSA-osv",
+		"summary": "osv advisory",
+		"affected": [{
+			"package": {"ecosystem": "GitHub Actions", "name": "other/action"},
+			"ranges": [{
+				"type": "ECOSYSTEM",
+				"events": [
+					{"introduced": "0"}, {"fixed": "1.4.0"},
+					{"introduced": "2.0.0"}, {"last_affected": "2.1.0"}
+				]
+			}],
+			"versions": ["3.0.0"]
+		}]
+	},
+	{
+		"compromised": [{
+			"id": "compromised",
+			"action": "other/action",
+			"summary": "compromised commit",
+			"shas": ["0E58ED8671D6B60D0890C21B07F8835ACE038E67"],
+			"fixed": "v46.0.1"
+		}]
+	}
+]`
+
+func TestMatch(t *testing.T) {
+	db := &Database{}
+	if err := db.parse("advisories.json", []byte(testAdvisories)); err != nil {
+		t.Fatalf("parse() error = %v", err)
+	}
+	tests := []struct {
+		action  string
+		sha     string
+		version string
+		want    []Match
+	}{
+		{"some/action", "", "v2.3.0", []Match{{ID: "GHSA-api", Summary: "api advisory", Fixed: "v2.3.1"}}},
+		{"Some/Action", "", "2.0.0", []Match{{ID: "GHSA-api", Summary: "api advisory", Fixed: "2.3.1"}}},
+		{"some/action", "", "v2.3.1", nil},
+		{"some/action", "", "", nil},
+		{"other/action", "", "v1.3.9", []Match{{ID: "GHSA-osv", Summary: "osv advisory", Fixed: "v1.4.0"}}},
+		{"other/action", "", "v1.4.0", nil},
+		{"other/action", "", "v2.1.0", []Match{{ID: "GHSA-osv", Summary: "osv advisory"}}},
+		{"other/action", "", "v2.1.1", nil},
+		{"other/action", "", "v3", []Match{{ID: "GHSA-osv", Summary: "osv advisory"}}},
+		{"other/action", "0e58ed8671d6b60d0890c21b07f8835ace038e67", "", []Match{{ID: "compromised", Summary: "compromised commit", Fixed: "v46.0.1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+"@"+tt.sha+tt.version, func(t *testing.T) {
+			got := db.Match(tt.action, tt.sha, tt.version)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Match() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if *got[i] != tt.want[i] {
+					t.Errorf("Match()[%d] = %+v, want %+v", i, *got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/koalalab-inc/pinny/pkg/utils"
+)
+
+// githubAppConfig is read from the environment:
+//   - PINNY_GITHUB_APP_ID
+//   - PINNY_GITHUB_APP_PRIVATE_KEY or PINNY_GITHUB_APP_PRIVATE_KEY_FILE
+//   - PINNY_GITHUB_APP_INSTALLATION_ID, optional if the app has a single
+//     installation
+//   - PINNY_GITHUB_APP_HOST, optional host the app is registered on, the
+//     default host when unset
+type githubAppConfig struct {
+	appID          int64
+	installationID int64
+	privateKey     *rsa.PrivateKey
+	// host is set if the app is registered on a single host
+	host string
+}
+
+// githubAppFromEnv returns the Github App configured for host, or nil if
+// there is none. The app's JWT is never sent to hosts other than the one it
+// is configured for.
+func githubAppFromEnv(host string, defaultHost bool) (*githubAppConfig, error) {
+	appIDString := strings.TrimSpace(os.Getenv("PINNY_GITHUB_APP_ID"))
+	if appIDString == "" {
+		return nil, nil
+	}
+	appHost := strings.TrimSpace(os.Getenv("PINNY_GITHUB_APP_HOST"))
+	if appHost != "" && appHost != host || appHost == "" && !defaultHost {
+		return nil, nil
+	}
+
+	appID, err := strconv.ParseInt(appIDString, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PINNY_GITHUB_APP_ID: %w", err)
+	}
+
+	config := &githubAppConfig{appID: appID, host: appHost}
+	if installationID := strings.TrimSpace(os.Getenv("PINNY_GITHUB_APP_INSTALLATION_ID")); installationID != "" {
+		config.installationID, err = strconv.ParseInt(installationID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PINNY_GITHUB_APP_INSTALLATION_ID: %w", err)
+		}
+	}
+
+	keyPEM := []byte(os.Getenv("PINNY_GITHUB_APP_PRIVATE_KEY"))
+	if keyFile := os.Getenv("PINNY_GITHUB_APP_PRIVATE_KEY_FILE"); len(keyPEM) == 0 && keyFile != "" {
+		keyPEM, err = os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(keyPEM) == 0 {
+		return nil, fmt.Errorf("neither PINNY_GITHUB_APP_PRIVATE_KEY nor PINNY_GITHUB_APP_PRIVATE_KEY_FILE is set for PINNY_GITHUB_APP_ID")
+	}
+	config.privateKey, err = parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func parsePrivateKey(keyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("invalid Github App private key, expected a PEM encoded RSA key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Github App private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid Github App private key, expected an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// jwt returns the short lived token the app authenticates as itself with.
+func (c *githubAppConfig) jwt() (string, error) {
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		// Backdated to allow for clock drift
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": c.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := fmt.Sprintf("%s.%s", encoding.EncodeToString(header), encoding.EncodeToString(claims))
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%s", unsigned, encoding.EncodeToString(signature)), nil
+}
+
+// lookupGithubApp exchanges the configured Github App's private key for an
+// installation token. An app without PINNY_GITHUB_APP_HOST is used on the
+// default host only, where not being installed gets no credential from it
+// rather than an error, so the sources after it are tried.
+func lookupGithubApp(apiURL string, defaultHost bool) (*Credential, error) {
+	config, err := githubAppFromEnv(Hostname(apiURL), defaultHost)
+	if config == nil || err != nil {
+		return nil, err
+	}
+	credential, err := config.installationToken(apiURL)
+	if err != nil && config.host == "" {
+		utils.Warnf("Not using Github App %d for %s: %s\n", config.appID, Hostname(apiURL), err)
+		return nil, nil
+	}
+	return credential, err
+}
+
+// client returns a client for the API at apiURL that authenticates as the
+// app itself.
+func (c *githubAppConfig) client(apiURL string) (*github.Client, error) {
+	jwt, err := c.jwt()
+	if err != nil {
+		return nil, err
+	}
+	client := github.NewClient(nil).WithAuthToken(jwt)
+	baseURL, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	client.BaseURL = baseURL
+	return client, nil
+}
+
+// installationToken creates a token for the installation of the app on the
+// host of apiURL.
+func (c *githubAppConfig) installationToken(apiURL string) (*Credential, error) {
+	installationID := c.installationID
+	if installationID == 0 {
+		client, err := c.client(apiURL)
+		if err != nil {
+			return nil, err
+		}
+		installations, _, err := client.Apps.ListInstallations(context.Background(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("listing installations of Github App %d: %w", c.appID, err)
+		}
+		if len(installations) != 1 {
+			return nil, fmt.Errorf("found %d installations of Github App %d, set PINNY_GITHUB_APP_INSTALLATION_ID", len(installations), c.appID)
+		}
+		installationID = installations[0].GetID()
+	}
+	return c.createInstallationToken(apiURL, installationID)
+}
+
+// createInstallationToken creates a token for installationID, which renews
+// itself by creating another one.
+func (c *githubAppConfig) createInstallationToken(apiURL string, installationID int64) (*Credential, error) {
+	client, err := c.client(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	token, _, err := client.Apps.CreateInstallationToken(context.Background(), installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating installation token for Github App %d: %w", c.appID, err)
+	}
+	return &Credential{
+		Token:     token.GetToken(),
+		Source:    fmt.Sprintf("Github App %d installation %d", c.appID, installationID),
+		expiresAt: token.GetExpiresAt().Time,
+		renew: func() (*Credential, error) {
+			return c.createInstallationToken(apiURL, installationID)
+		},
+	}, nil
+}
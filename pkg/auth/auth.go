@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens that expire are renewed this long before they do, so a request
+// never goes out with a token that expires on the way
+const renewalMargin = 5 * time.Minute
+
+// Credential is a token for a Github host along with where it came from.
+// Github App installation tokens expire after an hour and are renewed by
+// CurrentToken.
+type Credential struct {
+	Token  string
+	Source string
+
+	mu        sync.Mutex
+	expiresAt time.Time
+	renew     func() (*Credential, error)
+}
+
+// CurrentToken returns the token, renewed first if it is about to expire.
+func (c *Credential) CurrentToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.renew != nil && time.Until(c.expiresAt) < renewalMargin {
+		renewed, err := c.renew()
+		if err != nil {
+			return "", err
+		}
+		c.Token = renewed.Token
+		c.expiresAt = renewed.expiresAt
+	}
+	return c.Token, nil
+}
+
+// ID identifies the credential without revealing its token: the source of
+// tokens that are renewed, a hash of the token otherwise.
+func (c *Credential) ID() string {
+	if c.renew != nil {
+		return c.Source
+	}
+	sum := sha256.Sum256([]byte(c.Token))
+	return fmt.Sprintf("token-%s", hex.EncodeToString(sum[:4]))
+}
+
+// Hostname returns the host credentials for apiURL are stored under, e.g.
+// github.com for https://api.github.com/.
+func Hostname(apiURL string) string {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return apiURL
+	}
+	if u.Host == "api.github.com" {
+		return "github.com"
+	}
+	return u.Host
+}
+
+// Lookup returns the credential for the Github API at apiURL, or nil if
+// requests have to be made anonymously. defaultHost is set for the host
+// actions are resolved against unless they are routed elsewhere. Sources are
+// tried in order:
+//   - the environment variables in envVars
+//   - a Github App installation configured through PINNY_GITHUB_APP_ID
+//   - the hosts config of the gh CLI
+//   - ~/.netrc
+func Lookup(apiURL string, envVars []string, defaultHost bool) (*Credential, error) {
+	for _, name := range envVars {
+		token := strings.TrimSpace(os.Getenv(name))
+		if token != "" {
+			return &Credential{Token: token, Source: name}, nil
+		}
+	}
+
+	credential, err := lookupGithubApp(apiURL, defaultHost)
+	if credential != nil || err != nil {
+		return credential, err
+	}
+
+	host := Hostname(apiURL)
+	credential, err = lookupGhHosts(host)
+	if credential != nil || err != nil {
+		return credential, err
+	}
+
+	return lookupNetrc(host)
+}
+
+func homeFile(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate %s: %w", name, err)
+	}
+	return fmt.Sprintf("%s/%s", home, name), nil
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ghHost struct {
+	OauthToken string `yaml:"oauth_token"`
+	User       string `yaml:"user"`
+	Users      map[string]struct {
+		OauthToken string `yaml:"oauth_token"`
+	} `yaml:"users"`
+}
+
+func ghHostsFile() (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml"), nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml"), nil
+	}
+	return homeFile(".config/gh/hosts.yml")
+}
+
+// lookupGhHosts reads the token the gh CLI stored for host in plain text.
+// Tokens gh keeps in the system keyring are not visible here.
+func lookupGhHosts(host string) (*Credential, error) {
+	hostsFile, err := ghHostsFile()
+	if err != nil {
+		return nil, nil
+	}
+	content, err := os.ReadFile(hostsFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	hosts := make(map[string]*ghHost)
+	err = yaml.Unmarshal(content, &hosts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", hostsFile, err)
+	}
+
+	ghHost, ok := hosts[host]
+	if !ok || ghHost == nil {
+		return nil, nil
+	}
+	token := ghHost.OauthToken
+	if token == "" {
+		token = ghHost.Users[ghHost.User].OauthToken
+	}
+	if token == "" {
+		return nil, nil
+	}
+	return &Credential{Token: token, Source: hostsFile}, nil
+}
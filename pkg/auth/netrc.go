@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"os"
+	"strings"
+)
+
+func netrcFile() (string, error) {
+	if file := os.Getenv("NETRC"); file != "" {
+		return file, nil
+	}
+	return homeFile(".netrc")
+}
+
+// lookupNetrc returns the password of the netrc machine entry for host or
+// its api. subdomain.
+func lookupNetrc(host string) (*Credential, error) {
+	file, err := netrcFile()
+	if err != nil {
+		return nil, nil
+	}
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	machines := map[string]bool{
+		host:                             true,
+		"api." + host:                    true,
+		strings.TrimPrefix(host, "api."): true,
+	}
+
+	fields := strings.Fields(string(content))
+	matched := false
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				matched = machines[fields[i]]
+			}
+		case "default":
+			matched = false
+		case "macdef":
+			// macro definitions run until the end of the entry
+			matched = false
+		case "password":
+			if i+1 < len(fields) {
+				i++
+				if matched {
+					return &Credential{Token: fields[i], Source: file}, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// image digests, keyed by image name
+	KindImage = "image"
+	// Github API responses, keyed by URL
+	KindGithub = "github"
+)
+
+// Disabled turns the cache off, set with --no-cache.
+var Disabled = false
+
+// TTL is how long a cached result is used without asking Github or the
+// registry again, set with --cache-ttl.
+var TTL = 24 * time.Hour
+
+// Entry is a cached lookup result. Results fetched over HTTP keep the ETag
+// and Last-Modified of the response, so an expired entry can be revalidated
+// with a conditional request.
+type Entry struct {
+	Kind         string          `json:"kind"`
+	Key          string          `json:"key"`
+	Value        json.RawMessage `json:"value"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	StoredAt     time.Time       `json:"stored_at"`
+	ExpiresAt    time.Time       `json:"expires_at"`
+}
+
+// Expiry returns when the entry expires. Entries never outlive TTL, so
+// lowering --cache-ttl applies to entries stored before.
+func (e *Entry) Expiry() time.Time {
+	if maxExpiry := e.StoredAt.Add(TTL); maxExpiry.Before(e.ExpiresAt) {
+		return maxExpiry
+	}
+	return e.ExpiresAt
+}
+
+func (e *Entry) Expired() bool {
+	return time.Now().After(e.Expiry())
+}
+
+// Dir returns the directory the cache lives in, $XDG_CACHE_HOME/pinny or
+// the user cache directory of the platform.
+func Dir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "pinny"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pinny"), nil
+}
+
+func entryPath(kind string, key string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, kind, fmt.Sprintf("%s.json", hex.EncodeToString(sum[:]))), nil
+}
+
+func readEntry(file string) (*Entry, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{}
+	err = json.Unmarshal(content, entry)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return entry, nil
+}
+
+// Get returns the entry stored for key, even if it has expired.
+func Get(kind string, key string) (*Entry, bool) {
+	if Disabled {
+		return nil, false
+	}
+	file, err := entryPath(kind, key)
+	if err != nil {
+		return nil, false
+	}
+	entry, err := readEntry(file)
+	if err != nil || entry.Kind != kind || entry.Key != key {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Put stores entry. The cache is best effort, an entry that cannot be
+// written is dropped.
+func Put(entry *Entry) {
+	if Disabled {
+		return
+	}
+	file, err := entryPath(entry.Kind, entry.Key)
+	if err != nil {
+		return
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return
+	}
+	// entries are written by concurrent lookups, so write them to a unique
+	// file first and move it in place
+	tmpFile, err := os.CreateTemp(filepath.Dir(file), "*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmpFile.Write(content)
+	tmpFile.Close()
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return
+	}
+	os.Rename(tmpFile.Name(), file)
+}
+
+// Load unmarshals the value stored for key into value if it has not
+// expired yet.
+func Load(kind string, key string, value any) bool {
+	entry, ok := Get(kind, key)
+	if !ok || entry.Expired() {
+		return false
+	}
+	return json.Unmarshal(entry.Value, value) == nil
+}
+
+// Store stores value for key for ttl.
+func Store(kind string, key string, value any, ttl time.Duration) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	Put(&Entry{
+		Kind:      kind,
+		Key:       key,
+		Value:     content,
+		StoredAt:  now,
+		ExpiresAt: now.Add(ttl),
+	})
+}
+
+// List returns every cached entry, sorted by kind and key.
+func List() ([]*Entry, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	entries := []*Entry{}
+	for _, file := range files {
+		entry, err := readEntry(file)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+// Clear removes every cached entry, or only the entries of kind if it is
+// not empty. It returns the number of entries removed.
+func Clear(kind string) (int, error) {
+	dir, err := Dir()
+	if err != nil {
+		return 0, err
+	}
+	pattern := filepath.Join(dir, "*", "*.json")
+	if kind != "" {
+		pattern = filepath.Join(dir, kind, "*.json")
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		err = os.Remove(file)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
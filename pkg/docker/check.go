@@ -0,0 +1,130 @@
+package docker
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/findings"
+	"github.com/koalalab-inc/pinny/pkg/policy"
+	"github.com/koalalab-inc/pinny/pkg/utils"
+
+	"github.com/asottile/dockerfile"
+)
+
+func isDockerfile(name string) bool {
+	lowerName := strings.ToLower(name)
+	return lowerName == "dockerfile" ||
+		strings.HasPrefix(lowerName, "dockerfile.") ||
+		strings.HasSuffix(lowerName, ".dockerfile")
+}
+
+// FindDockerfiles returns every Dockerfile below the roots, which are
+// directories or files.
+func FindDockerfiles(roots ...string) ([]string, error) {
+	dockerfiles := []string{}
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && utils.SkippedDirs[d.Name()] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if isDockerfile(d.Name()) && !strings.HasSuffix(d.Name(), ".tmp") {
+				dockerfiles = append(dockerfiles, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dockerfiles, nil
+}
+
+// CheckDockerfile reports every FROM line of filename that references an
+// image by tag only or breaks the policy. It works offline.
+func CheckDockerfile(filename string) ([]*findings.Finding, error) {
+	p, err := policy.Load()
+	if err != nil {
+		return nil, err
+	}
+	commands, err := dockerfile.ParseFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return checkCommands(p, filename, commands)
+}
+
+// checkPolicy returns an error citing the rule for the first FROM line of
+// filename that breaks the policy, so nothing is pinned to a banned image.
+func checkPolicy(filename string, commands []dockerfile.Command) error {
+	p, err := policy.Load()
+	if err != nil {
+		return err
+	}
+	results, err := checkCommands(p, filename, commands)
+	if err != nil {
+		return err
+	}
+	for _, finding := range results {
+		if finding.Rule != "" {
+			return errors.New(finding.String())
+		}
+	}
+	return nil
+}
+
+func checkCommands(p *policy.Policy, filename string, commands []dockerfile.Command) ([]*findings.Finding, error) {
+	results := []*findings.Finding{}
+	stages := make(map[string]bool)
+	for _, cmd := range commands {
+		if cmd.Cmd != "FROM" {
+			continue
+		}
+		imageString, aliasString := getImageAndAliasFromCmd(cmd)
+		// Build stages, scratch and images chosen through build args can't
+		// be pinned
+		skip := imageString == "scratch" || stages[strings.ToLower(imageString)] || strings.Contains(imageString, "$")
+		if aliasString != "" {
+			stages[strings.ToLower(aliasString)] = true
+		}
+		if skip {
+			continue
+		}
+
+		imageRef, err := getImageRefFromImageString(imageString)
+		if err != nil {
+			return nil, err
+		}
+
+		finding := &findings.Finding{
+			File:    filename,
+			Line:    cmd.StartLine,
+			Ref:     imageString,
+			Message: "image is not pinned to a digest",
+		}
+		if violation := p.CheckImage(imageString); violation != nil {
+			finding.Message = violation.Message
+			finding.Rule = violation.Rule.String()
+			results = append(results, finding)
+			continue
+		}
+		if imageRef.Digest != "" {
+			continue
+		}
+		if imageRef.Tag == "" || imageRef.Tag == "latest" {
+			finding.Message = "image uses the latest tag, pin it to a digest"
+		}
+		if rule := p.DigestRule(imageString); rule != nil {
+			finding.Rule = rule.String()
+		}
+		results = append(results, finding)
+	}
+	return results, nil
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/koalalab-inc/pinny/pkg/cache"
 	"github.com/koalalab-inc/pinny/pkg/utils"
 
 	"github.com/asottile/dockerfile"
@@ -85,21 +86,65 @@ func (f *FromCmd) stringify(suffix string) string {
 	return resp
 }
 
+// digestCache maps image names to their digests. Concurrent lookups of an
+// image share a single request to the registry.
+var digestCache utils.Memo[string]
+
 func getDigest(imageName string) (*string, error) {
-	ref, err := alltransports.ParseImageName(imageName)
+	digest, err := digestCache.Do(imageName, func() (string, error) {
+		var digest string
+		if cache.Load(cache.KindImage, imageName, &digest) {
+			return digest, nil
+		}
+		digest, err := fetchDigest(imageName)
+		if err != nil {
+			return "", err
+		}
+		cache.Store(cache.KindImage, imageName, digest, cache.TTL)
+		return digest, nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return &digest, nil
+}
+
+func fetchDigest(imageName string) (string, error) {
+	ref, err := alltransports.ParseImageName(imageName)
+	if err != nil {
+		return "", err
+	}
 
 	ctx := context.Background()
 
 	digest, err := docker.GetDigest(ctx, nil, ref)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	digestStr := string(digest)
-	return &digestStr, nil
+	return string(digest), nil
+}
+
+// prefetchDigests looks up the digests of the images of the FROM commands
+// that are not pinned yet concurrently, so pinning them one after another
+// afterwards is served from the cache, or fails then.
+func prefetchDigests(commands []dockerfile.Command) {
+	imageNames := []string{}
+	for _, cmd := range commands {
+		if cmd.Cmd != "FROM" {
+			continue
+		}
+		imageString, _ := getImageAndAliasFromCmd(cmd)
+		imageRef, err := getImageRefFromImageString(imageString)
+		if err != nil || imageRef.Digest != "" {
+			continue
+		}
+		imageNames = append(imageNames, imageRef.fullName("tag"))
+	}
+	utils.ForEach(len(imageNames), func(i int) error {
+		getDigest(imageNames[i])
+		return nil
+	})
 }
 
 func GetDigest(imageString string) (*string, error) {
@@ -141,6 +186,11 @@ func GetImageRefWithDigest(imageString string) (*DockerImageRef, error) {
 	return imageRef, nil
 }
 
+// ParseImageRef parses an image reference without resolving its digest.
+func ParseImageRef(imageString string) (*DockerImageRef, error) {
+	return getImageRefFromImageString(imageString)
+}
+
 func GetManifest(imageName string) ([]byte, error) {
 	ref, err := alltransports.ParseImageName(imageName)
 	if err != nil {
@@ -203,6 +253,13 @@ func GeneratePinnedDockerfile(filename string, offline bool) error {
 	if err != nil {
 		return err
 	}
+	err = checkPolicy(filename, commands)
+	if err != nil {
+		return err
+	}
+	if !offline {
+		prefetchDigests(commands)
+	}
 
 	startLine := 1
 
@@ -275,7 +332,7 @@ func GeneratePinnedDockerfile(filename string, offline bool) error {
 	}
 
 	// copy remaining lines to destination from source file
-	if startLine < len(srcLines) {
+	if startLine <= len(srcLines) {
 		for i := startLine; i <= len(srcLines); i++ {
 			destFileWriter.WriteString(srcLines[i-1] + "\n")
 		}
@@ -313,6 +370,7 @@ func GeneratePinnyLockFile(filename string) error {
 	if err != nil {
 		return err
 	}
+	prefetchDigests(commands)
 
 	for _, cmd := range commands {
 		if cmd.Cmd == "FROM" {
@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/koalalab-inc/pinny/pkg/utils"
+
+	"github.com/asottile/dockerfile"
+)
+
+// The comment GeneratePinnedDockerfile writes above every FROM it pins
+var pinnedCommentRegex = regexp.MustCompile(`^# Pinned (?P<raw>\S+) using pinny( on .*)?$`)
+
+// GenerateUnpinnedDockerfile restores the base images pinned by pinny to the
+// images named in the `# Pinned <image> using pinny` comments above them,
+// drops the comments and writes the result to <filename>.unpinned.tmp. FROM
+// commands without such a comment are left alone.
+func GenerateUnpinnedDockerfile(filename string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	srcLines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+
+	commands, err := dockerfile.ParseFile(filename)
+	if err != nil {
+		return err
+	}
+
+	destLines := []string{}
+	startLine := 1
+	for _, cmd := range commands {
+		if cmd.Cmd != "FROM" || cmd.StartLine < 2 {
+			continue
+		}
+		ok, matches := utils.MatchNamedRegex(pinnedCommentRegex, strings.TrimSpace(srcLines[cmd.StartLine-2]))
+		if !ok {
+			continue
+		}
+		imageRef, err := getImageRefFromImageString(matches["raw"])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", filename, cmd.StartLine-1, err)
+		}
+		if imageRef.Digest != "" {
+			// pinned by hand before pinny saw it, nothing to restore
+			continue
+		}
+		_, aliasString := getImageAndAliasFromCmd(cmd)
+		fromCmd := &FromCmd{
+			Flags: cmd.Flags,
+			Image: imageRef,
+			Alias: aliasString,
+		}
+
+		// copy lines preceding the comment to destination from source file
+		destLines = append(destLines, srcLines[startLine-1:cmd.StartLine-2]...)
+		destLines = append(destLines, strings.TrimRight(fromCmd.stringify("tag"), " "))
+		startLine = cmd.EndLine + 1
+	}
+	if startLine <= len(srcLines) {
+		destLines = append(destLines, srcLines[startLine-1:]...)
+	}
+
+	destFilename := fmt.Sprintf("%s.unpinned.tmp", filename)
+	return os.WriteFile(destFilename, []byte(strings.Join(destLines, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,34 @@
+package findings
+
+import "fmt"
+
+// Finding is a problem with a single reference in a workflow, action
+// manifest or Dockerfile.
+type Finding struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Ref     string `json:"ref"`
+	Message string `json:"message"`
+	// Rule is the policy rule that fired, if the finding is a policy
+	// violation
+	Rule string `json:"rule,omitempty"`
+}
+
+func (f *Finding) String() string {
+	if f.Rule != "" {
+		return fmt.Sprintf("%s:%d: %s: %s (%s)", f.File, f.Line, f.Ref, f.Message, f.Rule)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", f.File, f.Line, f.Ref, f.Message)
+}
+
+// Exit codes used by commands that gate CI on findings
+const (
+	ExitUnpinned = 1
+	ExitImpostor = 2
+	ExitPolicy   = 3
+	ExitAdvisory = 4
+	ExitRepojack = 5
+	// ExitUnverified is used when a reference could not be checked, so a
+	// gate never passes on a failed lookup
+	ExitUnverified = 6
+)
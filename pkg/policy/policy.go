@@ -0,0 +1,358 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the policy file read from the repository root.
+const File = ".pinny.yaml"
+
+// policyFile is the layout of the policy file. Rules are kept as nodes so a
+// violation can point at the line of the rule that fired.
+type policyFile struct {
+	Actions actionRules `yaml:"actions"`
+	Images  imageRules  `yaml:"images"`
+}
+
+type actionRules struct {
+	// owner/repo patterns of actions that may stay on tags
+	Trusted []yaml.Node `yaml:"trusted"`
+	// owner/repo[/path][@ref] patterns of actions that must not be used
+	Deny             []yaml.Node `yaml:"deny"`
+	ForbidBranchRefs yaml.Node   `yaml:"forbid-branch-refs"`
+	// owner/repo patterns of actions whose tags must carry a verified
+	// signature
+	RequireSignedTags []yaml.Node `yaml:"require-signed-tags"`
+	// refuse unsigned tags of actions whose other releases are signed
+	RequireSignedTagsFromSigners yaml.Node `yaml:"require-signed-tags-from-signers"`
+}
+
+type imageRules struct {
+	// image[:tag] patterns of images that must not be used
+	Deny []yaml.Node `yaml:"deny"`
+	// registries whose images must be pinned to a digest, every registry if
+	// empty
+	RequireDigest []yaml.Node `yaml:"require-digest"`
+	ForbidLatest  yaml.Node   `yaml:"forbid-latest"`
+}
+
+// Rule is a single rule of the policy file.
+type Rule struct {
+	Section string
+	Pattern string
+	Line    int
+}
+
+func (r *Rule) String() string {
+	if r.Pattern == "" {
+		return fmt.Sprintf("%s:%d %s", File, r.Line, r.Section)
+	}
+	return fmt.Sprintf("%s:%d %s %s", File, r.Line, r.Section, r.Pattern)
+}
+
+// Violation is a reference that breaks a rule of the policy.
+type Violation struct {
+	Rule    *Rule
+	Message string
+}
+
+type Policy struct {
+	TrustedActions   []*Rule
+	DeniedActions    []*Rule
+	ForbidBranchRefs *Rule
+	SignedTags       []*Rule
+	SignedBySigners  *Rule
+	DeniedImages     []*Rule
+	RequireDigest    []*Rule
+	ForbidLatest     *Rule
+}
+
+var loadOnce sync.Once
+var loaded *Policy
+var loadErr error
+
+// Load reads the policy file of the repository in the working directory. The
+// file is read once, an empty policy is returned if there is none.
+func Load() (*Policy, error) {
+	loadOnce.Do(func() {
+		loaded, loadErr = readPolicy(File)
+	})
+	return loaded, loadErr
+}
+
+func readPolicy(file string) (*Policy, error) {
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return &Policy{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	raw := &policyFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err = decoder.Decode(raw)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	p := &Policy{}
+	p.TrustedActions, err = patternRules("actions.trusted", raw.Actions.Trusted)
+	if err != nil {
+		return nil, err
+	}
+	p.DeniedActions, err = patternRules("actions.deny", raw.Actions.Deny)
+	if err != nil {
+		return nil, err
+	}
+	p.ForbidBranchRefs, err = switchRule("actions.forbid-branch-refs", &raw.Actions.ForbidBranchRefs)
+	if err != nil {
+		return nil, err
+	}
+	p.SignedTags, err = patternRules("actions.require-signed-tags", raw.Actions.RequireSignedTags)
+	if err != nil {
+		return nil, err
+	}
+	p.SignedBySigners, err = switchRule("actions.require-signed-tags-from-signers", &raw.Actions.RequireSignedTagsFromSigners)
+	if err != nil {
+		return nil, err
+	}
+	p.DeniedImages, err = patternRules("images.deny", raw.Images.Deny)
+	if err != nil {
+		return nil, err
+	}
+	p.RequireDigest, err = patternRules("images.require-digest", raw.Images.RequireDigest)
+	if err != nil {
+		return nil, err
+	}
+	p.ForbidLatest, err = switchRule("images.forbid-latest", &raw.Images.ForbidLatest)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func patternRules(section string, nodes []yaml.Node) ([]*Rule, error) {
+	rules := []*Rule{}
+	for _, node := range nodes {
+		if node.Kind != yaml.ScalarNode || node.Value == "" {
+			return nil, fmt.Errorf("%s:%d: %s expects a list of patterns", File, node.Line, section)
+		}
+		_, err := path.Match(node.Value, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q in %s", File, node.Line, node.Value, section)
+		}
+		rules = append(rules, &Rule{
+			Section: section,
+			Pattern: node.Value,
+			Line:    node.Line,
+		})
+	}
+	return rules, nil
+}
+
+// switchRule returns the rule of an on/off setting, or nil if it is off.
+func switchRule(section string, node *yaml.Node) (*Rule, error) {
+	if node.Kind == 0 {
+		return nil, nil
+	}
+	var on bool
+	err := node.Decode(&on)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%d: %s expects true or false", File, node.Line, section)
+	}
+	if !on {
+		return nil, nil
+	}
+	return &Rule{
+		Section: section,
+		Line:    node.Line,
+	}, nil
+}
+
+// actionName returns owner/repo[/path] of an action in lower case, as Github
+// owner and repo names are case insensitive.
+func actionName(owner string, repo string, actionPath string) string {
+	name := fmt.Sprintf("%s/%s", owner, repo)
+	if actionPath != "" {
+		name = fmt.Sprintf("%s/%s", name, actionPath)
+	}
+	return strings.ToLower(name)
+}
+
+// matchAction reports whether an action pattern matches. A pattern without
+// a slash names an owner, a pattern without @ matches every ref and a
+// pattern for owner/repo matches the actions in subdirectories of the repo
+// as well.
+func matchAction(pattern string, name string, ref string) bool {
+	pattern = strings.ToLower(pattern)
+	refPattern := "*"
+	if i := strings.LastIndex(pattern, "@"); i >= 0 {
+		pattern, refPattern = pattern[:i], pattern[i+1:]
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = fmt.Sprintf("%s/*", pattern)
+	}
+	if ok, _ := path.Match(refPattern, ref); !ok {
+		return false
+	}
+	if ok, _ := path.Match(pattern, name); ok {
+		return true
+	}
+	// owner/repo/path is matched by owner/repo and owner/*
+	parts := strings.SplitN(name, "/", 3)
+	ok, _ := path.Match(pattern, strings.Join(parts[:2], "/"))
+	return ok
+}
+
+// TrustedAction returns the rule that trusts an action reference to stay on
+// a tag, or nil if it is not trusted.
+func (p *Policy) TrustedAction(owner string, repo string, actionPath string, ref string) *Rule {
+	name := actionName(owner, repo, actionPath)
+	for _, rule := range p.TrustedActions {
+		if matchAction(rule.Pattern, name, ref) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// CheckAction returns the violation of an action reference, or nil if the
+// policy allows it. refType is "branch" if ref is known to be a branch.
+func (p *Policy) CheckAction(owner string, repo string, actionPath string, ref string, refType string) *Violation {
+	name := actionName(owner, repo, actionPath)
+	for _, rule := range p.DeniedActions {
+		if matchAction(rule.Pattern, name, ref) {
+			return &Violation{
+				Rule:    rule,
+				Message: "action is denied by the policy",
+			}
+		}
+	}
+	if refType == "branch" && p.ForbidBranchRefs != nil {
+		return &Violation{
+			Rule:    p.ForbidBranchRefs,
+			Message: "branch refs are forbidden by the policy",
+		}
+	}
+	return nil
+}
+
+// CheckSignature returns the violation of an action reference resolved from
+// a tag whose signature Github did not verify, or nil if the policy allows
+// it. Other refs are always allowed, as only tags are expected to be signed.
+// signsReleases reports whether the other releases of the action are signed,
+// it is only called when the policy refuses unsigned tags from signers.
+func (p *Policy) CheckSignature(owner string, repo string, actionPath string, ref string, refType string, verified bool, signsReleases func() bool) *Violation {
+	if refType != "tag" || verified {
+		return nil
+	}
+	name := actionName(owner, repo, actionPath)
+	for _, rule := range p.SignedTags {
+		if matchAction(rule.Pattern, name, ref) {
+			return &Violation{
+				Rule:    rule,
+				Message: "tag has no verified signature, the policy requires signed tags",
+			}
+		}
+	}
+	if p.SignedBySigners != nil && signsReleases() {
+		return &Violation{
+			Rule:    p.SignedBySigners,
+			Message: "tag has no verified signature, unlike the other releases of the action",
+		}
+	}
+	return nil
+}
+
+// SignatureRule returns the rule that requires the tag of an action
+// reference to be signed, or nil if there is none.
+func (p *Policy) SignatureRule(owner string, repo string, actionPath string, ref string) *Rule {
+	name := actionName(owner, repo, actionPath)
+	for _, rule := range p.SignedTags {
+		if matchAction(rule.Pattern, name, ref) {
+			return rule
+		}
+	}
+	return p.SignedBySigners
+}
+
+// normalizeImageName fills in the registry and namespace of Docker Hub, so
+// alpine and docker.io/library/alpine are the same image.
+func normalizeImageName(name string) string {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 1 {
+		return fmt.Sprintf("docker.io/library/%s", name)
+	}
+	if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		return fmt.Sprintf("docker.io/%s", name)
+	}
+	return name
+}
+
+// splitImage splits an image reference without digest into its normalized
+// name and tag.
+func splitImage(image string) (string, string) {
+	image = strings.TrimPrefix(image, "docker://")
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	tag := ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, tag = image[:i], image[i+1:]
+	}
+	return normalizeImageName(image), tag
+}
+
+// CheckImage returns the violation of an image reference like alpine:3.18,
+// docker://ghcr.io/org/image:v1 or alpine@sha256:..., or nil if the policy
+// allows it.
+func (p *Policy) CheckImage(image string) *Violation {
+	name, tag := splitImage(image)
+	for _, rule := range p.DeniedImages {
+		patternName, patternTag := splitImage(rule.Pattern)
+		if patternTag == "" {
+			patternTag = "*"
+		}
+		nameOk, _ := path.Match(patternName, name)
+		tagOk, _ := path.Match(patternTag, tag)
+		if nameOk && tagOk {
+			return &Violation{
+				Rule:    rule,
+				Message: "image is denied by the policy",
+			}
+		}
+	}
+	// the tag of an image pinned to a digest without a tag is unknown
+	pinnedWithoutTag := strings.Contains(image, "@") && tag == ""
+	if p.ForbidLatest != nil && (tag == "latest" || tag == "" && !pinnedWithoutTag) {
+		return &Violation{
+			Rule:    p.ForbidLatest,
+			Message: "latest and untagged images are forbidden by the policy",
+		}
+	}
+	return nil
+}
+
+// DigestRule returns the require-digest rule of the registry of image, or
+// nil if there is none. Images are always expected to be pinned to a digest,
+// the rule makes an image that isn't a policy violation as well.
+func (p *Policy) DigestRule(image string) *Rule {
+	name, _ := splitImage(image)
+	registry := strings.SplitN(name, "/", 2)[0]
+	for _, rule := range p.RequireDigest {
+		if ok, _ := path.Match(rule.Pattern, registry); ok {
+			return rule
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Lines of unchanged context printed around every change
+const diffContext = 3
+
+// Printed after the last line of a file that doesn't end with a newline
+const noNewlineMarker = `\ No newline at end of file`
+
+// ANSI colours of the parts of a diff
+const (
+	colorReset = "\033[0m"
+	colorBold  = "\033[1m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+)
+
+// diffLine is a line of an edit script: ' ' for a line both sides share,
+// '-' for a line of a only and '+' for a line of b only.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffInput splits content into the lines to diff. A last line without a
+// newline carries the marker that says so, which sets it apart from the
+// same line with a newline.
+func diffInput(content []byte) []string {
+	lines := splitLines(string(content))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines[len(lines)-1] += "\n" + noNewlineMarker
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script turning a into b, found with
+// Myers' algorithm.
+func diffLines(a []string, b []string) []diffLine {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	// trace holds v as it was before every round, to walk the edits back
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	script := []diffLine{}
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			script = append(script, diffLine{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			script = append(script, diffLine{'+', b[y-1]})
+			y--
+		} else {
+			script = append(script, diffLine{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		script = append(script, diffLine{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(script)-1; i < j; i, j = i+1, j-1 {
+		script[i], script[j] = script[j], script[i]
+	}
+	return script
+}
+
+// hunkRange formats the start and length of a hunk the way unified diffs
+// do, an empty hunk starts at the line before it.
+func hunkRange(start int, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// UnifiedDiff returns the unified diff of turning oldContent, the content of
+// oldName, into newContent, the content of newName, with three lines of
+// context around every change. It is empty if the contents are the same.
+func UnifiedDiff(oldName string, newName string, oldContent []byte, newContent []byte) string {
+	if string(oldContent) == string(newContent) {
+		return ""
+	}
+	script := diffLines(diffInput(oldContent), diffInput(newContent))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	// aLines and bLines are the lines of the old and new content preceding
+	// every line of the script
+	aLines := make([]int, len(script)+1)
+	bLines := make([]int, len(script)+1)
+	for i, line := range script {
+		aLines[i+1], bLines[i+1] = aLines[i], bLines[i]
+		if line.op != '+' {
+			aLines[i+1]++
+		}
+		if line.op != '-' {
+			bLines[i+1]++
+		}
+	}
+
+	for i := 0; i < len(script); {
+		if script[i].op == ' ' {
+			i++
+			continue
+		}
+		// a hunk runs until the changes are more than twice the context
+		// lines apart
+		start := max(i-diffContext, 0)
+		end := i
+		for j := i; j < len(script) && j-end <= 2*diffContext+1; j++ {
+			if script[j].op != ' ' {
+				end = j
+			}
+		}
+		end = min(end+diffContext+1, len(script))
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(aLines[start], aLines[end]-aLines[start]),
+			hunkRange(bLines[start], bLines[end]-bLines[start]))
+		for _, line := range script[start:end] {
+			fmt.Fprintf(&sb, "%c%s\n", line.op, line.text)
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// ColorizeDiff colours the file headers, hunk headers, removed and added
+// lines of a unified diff.
+func ColorizeDiff(diff string) string {
+	lines := splitLines(diff)
+	for i, line := range lines {
+		color := ""
+		switch {
+		case i < 2:
+			color = colorBold
+		case strings.HasPrefix(line, "@@"):
+			color = colorCyan
+		case strings.HasPrefix(line, "-"):
+			color = colorRed
+		case strings.HasPrefix(line, "+"):
+			color = colorGreen
+		}
+		if color != "" {
+			lines[i] = color + line + colorReset
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// IsTerminal reports whether f is attached to a terminal. Setting NO_COLOR
+// makes it report false, so output is never coloured.
+func IsTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// PrintDiff prints the unified diff of oldContent and newContent to out,
+// coloured if out is a terminal. Nothing is printed if they are the same.
+func PrintDiff(out io.Writer, oldName string, newName string, oldContent []byte, newContent []byte) {
+	diff := UnifiedDiff(oldName, newName, oldContent, newContent)
+	if diff == "" {
+		return
+	}
+	if f, ok := out.(*os.File); ok && IsTerminal(f) {
+		diff = ColorizeDiff(diff)
+	}
+	fmt.Fprint(out, diff)
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns the lines from to to, each holding its number.
+func numberedLines(from int, to int) string {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&sb, "%d\n", i)
+	}
+	return sb.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "same",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "removed line",
+			old:  "a\nb\nc\n",
+			new:  "a\nc\n",
+			want: "@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+		},
+		{
+			name: "empty file",
+			old:  "",
+			new:  "a\n",
+			want: "@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "no newline at end of file",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "changes within the context share a hunk",
+			old:  numberedLines(1, 9),
+			new:  strings.NewReplacer("2\n", "two\n", "8\n", "eight\n").Replace(numberedLines(1, 9)),
+			want: "@@ -1,9 +1,9 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n",
+		},
+		{
+			name: "changes far apart get their own hunks",
+			old:  numberedLines(1, 20),
+			new:  numberedLines(1, 1) + "two\n" + numberedLines(3, 17) + "eighteen\n" + numberedLines(19, 20),
+			want: "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want != "" {
+				want = "--- a.yml\n+++ b.yml\n" + want
+			}
+			got := UnifiedDiff("a.yml", "b.yml", []byte(tt.old), []byte(tt.new))
+			if got != want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import "sync"
+
+// Jobs is how many lookups run at the same time, set with --jobs.
+var Jobs = 8
+
+// ForEach calls fn for every i in [0, n) on up to Jobs goroutines. It returns
+// the error of the lowest i that failed, so the error does not depend on the
+// order the calls finished in.
+func ForEach(n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	slots := make(chan struct{}, max(Jobs, 1))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Memo caches the results of a lookup by key and is safe for concurrent use.
+// Concurrent lookups of a key that is not cached yet share a single call.
+// Errors are not cached, so a failed lookup is retried the next time.
+type Memo[T any] struct {
+	mu     sync.Mutex
+	values map[string]T
+	group  singleflight.Group
+}
+
+// Get returns the cached value of key.
+func (m *Memo[T]) Get(key string) (T, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Do returns the cached value of key, calling fn to look it up if there is
+// none.
+func (m *Memo[T]) Do(key string, fn func() (T, error)) (T, error) {
+	if value, ok := m.Get(key); ok {
+		return value, nil
+	}
+	value, err, _ := m.group.Do(key, func() (any, error) {
+		// a call that just finished may have stored the value already
+		if value, ok := m.Get(key); ok {
+			return value, nil
+		}
+		value, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.values == nil {
+			m.values = make(map[string]T)
+		}
+		m.values[key] = value
+		return value, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return value.(T), nil
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var semverRegex = regexp.MustCompile(`^(?P<prefix>v?)(?P<major>0|[1-9]\d*)(\.(?P<minor>0|[1-9]\d*))?(\.(?P<patch>0|[1-9]\d*))?(?P<prerelease>-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+var numericIdentifierRegex = regexp.MustCompile(`^(0|[1-9]\d*)$`)
+
+// Semver is a parsed version tag such as v3, v3.5 or v3.5.1. Precision is
+// the number of version components present in the tag.
+type Semver struct {
+	Raw        string
+	Prefix     string
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+	Precision  int
+}
+
+func ParseSemver(tag string) (*Semver, bool) {
+	ok, matches := MatchNamedRegex(semverRegex, tag)
+	if !ok {
+		return nil, false
+	}
+	version := &Semver{
+		Raw:        tag,
+		Prefix:     matches["prefix"],
+		Prerelease: matches["prerelease"],
+		Precision:  1,
+	}
+	version.Major, _ = strconv.Atoi(matches["major"])
+	if matches["minor"] != "" {
+		version.Minor, _ = strconv.Atoi(matches["minor"])
+		version.Precision = 2
+	}
+	if matches["patch"] != "" {
+		version.Patch, _ = strconv.Atoi(matches["patch"])
+		version.Precision = 3
+	}
+	return version, true
+}
+
+// Compare returns -1, 0 or 1. Versions that are numerically equal are
+// ordered by precision, so v3 < v3.0 < v3.0.0, and prereleases sort before
+// the release they precede.
+func (s *Semver) Compare(other *Semver) int {
+	for _, pair := range [][2]int{
+		{s.Major, other.Major},
+		{s.Minor, other.Minor},
+		{s.Patch, other.Patch},
+	} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	if s.Prerelease != other.Prerelease {
+		if s.Prerelease == "" {
+			return 1
+		} else if other.Prerelease == "" {
+			return -1
+		}
+		return comparePrerelease(s.Prerelease, other.Prerelease)
+	}
+	if s.Precision != other.Precision {
+		if s.Precision < other.Precision {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease orders prereleases like -rc.2 and -rc.10 the way SemVer
+// does: identifier by identifier, numeric ones numerically and before
+// alphanumeric ones, and a prerelease before the longer ones it starts.
+func comparePrerelease(a string, b string) int {
+	aIdentifiers := strings.Split(strings.TrimPrefix(a, "-"), ".")
+	bIdentifiers := strings.Split(strings.TrimPrefix(b, "-"), ".")
+	for i := 0; i < len(aIdentifiers) && i < len(bIdentifiers); i++ {
+		x, y := aIdentifiers[i], bIdentifiers[i]
+		if x == y {
+			continue
+		}
+		xNumeric, yNumeric := numericIdentifierRegex.MatchString(x), numericIdentifierRegex.MatchString(y)
+		switch {
+		case xNumeric && yNumeric:
+			// without leading zeros the longer number is the larger one
+			if len(x) != len(y) {
+				if len(x) < len(y) {
+					return -1
+				}
+				return 1
+			}
+			if x < y {
+				return -1
+			}
+			return 1
+		case xNumeric:
+			return -1
+		case yNumeric:
+			return 1
+		case x < y:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(aIdentifiers) < len(bIdentifiers):
+		return -1
+	case len(aIdentifiers) > len(bIdentifiers):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package utils
+
+import "testing"
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		tag        string
+		ok         bool
+		major      int
+		minor      int
+		patch      int
+		prerelease string
+		precision  int
+	}{
+		{tag: "v3", ok: true, major: 3, precision: 1},
+		{tag: "v3.5", ok: true, major: 3, minor: 5, precision: 2},
+		{tag: "v3.5.1", ok: true, major: 3, minor: 5, patch: 1, precision: 3},
+		{tag: "3.5.1", ok: true, major: 3, minor: 5, patch: 1, precision: 3},
+		{tag: "v1.0.0-rc.10", ok: true, major: 1, prerelease: "-rc.10", precision: 3},
+		{tag: "v1.0.0+build.5", ok: true, major: 1, precision: 3},
+		{tag: "main", ok: false},
+		{tag: "v01", ok: false},
+		{tag: "v1.2.3.4", ok: false},
+		{tag: "", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			version, ok := ParseSemver(tt.tag)
+			if ok != tt.ok {
+				t.Fatalf("ParseSemver(%q) ok = %v, want %v", tt.tag, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if version.Major != tt.major || version.Minor != tt.minor || version.Patch != tt.patch {
+				t.Errorf("ParseSemver(%q) = %d.%d.%d, want %d.%d.%d", tt.tag, version.Major, version.Minor, version.Patch, tt.major, tt.minor, tt.patch)
+			}
+			if version.Prerelease != tt.prerelease {
+				t.Errorf("ParseSemver(%q) prerelease = %q, want %q", tt.tag, version.Prerelease, tt.prerelease)
+			}
+			if version.Precision != tt.precision {
+				t.Errorf("ParseSemver(%q) precision = %d, want %d", tt.tag, version.Precision, tt.precision)
+			}
+		})
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "v1.2.4", -1},
+		{"v1.10.0", "v1.9.0", 1},
+		{"v2", "v1.99.99", 1},
+		{"v3", "v3.0", -1},
+		{"v3.0", "v3.0.0", -1},
+		{"v1.0.0-rc.1", "v1.0.0", -1},
+		{"v1.0.0", "v1.0.0-rc.1", 1},
+		{"v1.0.0-rc.2", "v1.0.0-rc.10", -1},
+		{"v1.0.0-rc.10", "v1.0.0-rc.2", 1},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1", -1},
+		{"v1.0.0-alpha.1", "v1.0.0-alpha.beta", -1},
+		{"v1.0.0-alpha.beta", "v1.0.0-beta", -1},
+		{"v1.0.0-beta.11", "v1.0.0-rc.1", -1},
+		{"v1.0.0-1", "v1.0.0-alpha", -1},
+		{"v1.0.0-rc.1", "v1.0.0-rc.1", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			a, ok := ParseSemver(tt.a)
+			if !ok {
+				t.Fatalf("ParseSemver(%q) failed", tt.a)
+			}
+			b, ok := ParseSemver(tt.b)
+			if !ok {
+				t.Fatalf("ParseSemver(%q) failed", tt.b)
+			}
+			if got := a.Compare(b); got != tt.want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -15,3 +15,11 @@ func MatchNamedRegex(regex *regexp.Regexp, str string) (bool, map[string]string)
 	}
 	return false, nil
 }
+
+// SkippedDirs are the directories that never hold files worth pinning, left
+// out when searching for workflows and Dockerfiles.
+var SkippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+}
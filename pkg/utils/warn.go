@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+)
+
+// Warnf prints a warning to stderr so it never mixes with command output.
+func Warnf(format string, a ...any) {
+	fmt.Fprintf(os.Stderr, "WARN:: "+format, a...)
+}
+
+// Warnings collects warnings to print them later, so the warnings of lookups
+// done concurrently are printed in a deterministic order.
+type Warnings []string
+
+func (w *Warnings) Warnf(format string, a ...any) {
+	*w = append(*w, fmt.Sprintf(format, a...))
+}
+
+// Print prints the collected warnings like Warnf.
+func (w Warnings) Print() {
+	for _, warning := range w {
+		Warnf("%s", warning)
+	}
+}